@@ -0,0 +1,259 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/cockroachdb/errors"
+	"github.com/shihanng/tfvar/pkg/configs"
+	"github.com/shihanng/tfvar/pkg/tfvar"
+	"github.com/spf13/cobra"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// recursivePattern at the end of a DIR argument matches DIR and all of its subdirectories,
+// as in Go's package patterns.
+const recursivePattern = "..."
+
+// expandDirs expands the DIR/... patterns in args into the root modules, i.e. the directories
+// containing Terraform configuration files, under DIR. isBatch is true when more than one DIR
+// or any pattern is given.
+func expandDirs(args []string) (dirs []string, isBatch bool, err error) {
+	isBatch = len(args) > 1
+
+	for _, arg := range args {
+		if arg != recursivePattern && !strings.HasSuffix(arg, "/"+recursivePattern) {
+			dirs = append(dirs, arg)
+			continue
+		}
+
+		isBatch = true
+
+		root := strings.TrimSuffix(strings.TrimSuffix(arg, recursivePattern), "/")
+		if root == "" {
+			root = "."
+		}
+
+		found, err := findRootModules(root)
+		if err != nil {
+			return nil, false, err
+		}
+
+		dirs = append(dirs, found...)
+	}
+
+	if len(dirs) == 0 {
+		return nil, false, errors.Errorf("cmd: no Terraform configuration found in %s", strings.Join(args, ", "))
+	}
+
+	return dirs, isBatch, nil
+}
+
+// findRootModules returns root and its subdirectories that contain Terraform configuration files,
+// in lexical order. Hidden directories, e.g. .terraform, are skipped.
+func findRootModules(root string) ([]string, error) {
+	parser := configs.NewParser(nil)
+
+	var dirs []string
+
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.IsDir() {
+			return nil
+		}
+
+		if path != root && configs.IsIgnoredFile(info.Name()) {
+			return filepath.SkipDir
+		}
+
+		if parser.IsConfigDir(path) {
+			dirs = append(dirs, path)
+		}
+
+		return nil
+	})
+
+	return dirs, errors.Wrapf(err, "cmd: searching modules in '%s'", root)
+}
+
+// batchReport summarizes the variables without value of the modules processed in batch mode.
+type batchReport struct {
+	Modules []moduleReport `json:"modules"`
+	// Missing is the total number of variables without value.
+	Missing int `json:"missing"`
+	// Failed is the number of modules that could not be processed.
+	Failed int `json:"failed"`
+}
+
+type moduleReport struct {
+	Dir     string   `json:"dir"`
+	Missing []string `json:"missing"`
+	Error   string   `json:"error,omitempty"`
+}
+
+type batchResult struct {
+	out      bytes.Buffer
+	missing  []string
+	warnings []tfvar.Warning
+	err      error
+}
+
+// batch runs the same pipeline for each of dirs concurrently and writes the output of each module
+// after a "==> DIR <==" header, in the order of dirs, or into the files given by output.
+func (r *runner) batch(cmd *cobra.Command, dirs []string, opts tfvar.Options, output outputOptions, format string, writer tfvar.Writer) error {
+	parallelism, err := cmd.Flags().GetInt(flagParallel)
+	if err != nil {
+		return errors.Wrap(err, "cmd: get flag --parallelism")
+	}
+
+	if parallelism < 1 {
+		return errors.Errorf("cmd: --parallelism must be at least 1, got %d", parallelism)
+	}
+
+	reportFile, err := cmd.Flags().GetString(flagReport)
+	if err != nil {
+		return errors.Wrap(err, "cmd: get flag --report")
+	}
+
+	if output.pattern != "" && !output.isPerModule() {
+		return errors.Errorf("cmd: --output must contain %s or %s with multiple directories", placeholderDir, placeholderBase)
+	}
+
+	if output.pattern != "" {
+		if err := output.checkOutputPaths(dirs, format); err != nil {
+			return err
+		}
+	}
+
+	r.log.Debugf("Processing %d modules with parallelism %d", len(dirs), parallelism)
+
+	results := make([]batchResult, len(dirs))
+	sem := make(chan struct{}, parallelism)
+
+	var wg sync.WaitGroup
+
+	for i, dir := range dirs {
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func(res *batchResult, dir string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			vars, warnings, err := r.load(cmd.Context(), dir, opts)
+			if err != nil {
+				res.err = err
+				return
+			}
+
+			res.warnings = warnings
+
+			res.missing = missingVariables(vars)
+
+			if output.pattern != "" {
+				res.err = r.writeOutput(output, dir, format, writer, vars)
+				return
+			}
+
+			res.err = writer.Write(&res.out, vars)
+		}(&results[i], dir)
+	}
+
+	wg.Wait()
+
+	report := batchReport{
+		Modules: make([]moduleReport, 0, len(dirs)),
+	}
+
+	printed := false
+
+	for i, dir := range dirs {
+		res := &results[i]
+		m := moduleReport{
+			Dir:     dir,
+			Missing: res.missing,
+		}
+
+		if res.err != nil {
+			if !r.jsonDiags {
+				r.log.Errorf("%s: %v", dir, res.err)
+			}
+
+			m.Error = res.err.Error()
+			report.Failed++
+		} else if output.pattern == "" {
+			if printed {
+				fmt.Fprintln(r.out)
+			}
+
+			fmt.Fprintf(r.out, "==> %s <==\n", dir)
+			printed = true
+
+			if _, err := res.out.WriteTo(r.out); err != nil {
+				return errors.Wrap(err, "cmd: unexpected writing output")
+			}
+		}
+
+		if m.Missing == nil {
+			m.Missing = []string{}
+		}
+
+		report.Missing += len(m.Missing)
+		report.Modules = append(report.Modules, m)
+	}
+
+	if reportFile != "" {
+		b, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "cmd: unexpected encoding report")
+		}
+
+		if err := writeFileAtomic(reportFile, append(b, '\n'), 0644); err != nil {
+			return errors.Wrapf(err, "cmd: writing file '%s'", reportFile)
+		}
+	}
+
+	var failed error
+	if report.Failed > 0 {
+		failed = errors.Errorf("cmd: %d of %d modules failed", report.Failed, len(dirs))
+	}
+
+	if r.jsonDiags {
+		modules := make([]tfvar.ModuleDiagnostics, 0, len(dirs))
+		for i, dir := range dirs {
+			modules = append(modules, tfvar.ModuleDiagnostics{Dir: dir, Err: results[i].err, Warnings: results[i].warnings})
+		}
+
+		if werr := tfvar.WriteModulesDiagnosticsJSON(cmd.ErrOrStderr(), modules); werr != nil {
+			return werr
+		}
+
+		if failed != nil {
+			return &reportedError{cause: failed}
+		}
+	}
+
+	return failed
+}
+
+// missingVariables returns the names of vars that Terraform requires but are not assigned
+// any value. Variables declared with default = null are not required.
+func missingVariables(vars []tfvar.Variable) []string {
+	var missing []string
+
+	for _, v := range vars {
+		if v.Required && v.Value == cty.NilVal {
+			missing = append(missing, v.Name)
+		}
+	}
+
+	return missing
+}
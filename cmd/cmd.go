@@ -1,22 +1,47 @@
 package cmd
 
 import (
+	"bytes"
+	"context"
 	"io"
-	"sort"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/cockroachdb/errors"
+	"github.com/pmezard/go-difflib/difflib"
 	"github.com/shihanng/tfvar/pkg/tfvar"
 	"github.com/spf13/cobra"
-	"github.com/zclconf/go-cty/cty"
 	"go.uber.org/zap"
 )
 
 const (
+	flagAllowFuncs = "allow-functions"
 	flagAutoAssign = "auto-assign"
 	flagDebug      = "debug"
+	flagDryRun     = "dry-run"
 	flagEnvVar     = "env-var"
+	flagForce      = "force"
+	flagFormat     = "format"
+	flagFromOutput = "from-outputs"
+	flagFromPlan   = "from-plan"
+	flagJSONDiags  = "json-diagnostics"
+	flagLenient    = "lenient"
+	flagModule     = "module"
 	flagNoDefault  = "ignore-default"
+	flagOutput     = "output"
+	flagOutputDir  = "output-dir"
+	flagOutputMap  = "outputs-mapping"
+	flagParallel   = "parallelism"
+	flagPrune      = "prune"
+	flagReport     = "report"
 	flagResource   = "resource"
+	flagStdinFmt   = "stdin-format"
+	flagSync       = "sync"
+	flagTemplate   = "template"
+	flagTFCLIArgs  = "tf-cli-args"
+	flagTofu       = "tofu"
 	flagVar        = "var"
 	flagVarFile    = "var-file"
 	flagWorkspace  = "workspace"
@@ -34,30 +59,87 @@ func New(out io.Writer, version string) (*cobra.Command, func()) {
 	}
 
 	rootCmd := &cobra.Command{
-		Use:   "tfvar [DIR]",
+		Use:   "tfvar [DIR|ARCHIVE]...",
 		Short: "A CLI tool that helps generate template for Terraform's variable definitions",
 		Long: `Generate variable definitions template for Terraform module as
 one would write it in variable definitions files (.tfvars).
+
+The module can also be read from a .zip, .tar.gz, or .tgz archive without
+extracting it. A subdirectory within the archive can be selected with a
+double-slash, e.g. module.zip//modules/vpc.
+
+Several modules can be processed at once by giving more than one DIR or
+a DIR/... pattern that matches every directory containing .tf files
+under DIR, e.g. live/... The output of each module is preceded by a
+==> DIR <== header.
 `,
-		PreRunE: r.preRootRunE,
-		RunE:    r.rootRunE,
-		Args:    cobra.ExactArgs(1),
-		Version: version,
+		PersistentPreRunE: r.preRootRunE,
+		RunE:              r.reportErrors(r.rootRunE),
+		Args:              cobra.MinimumNArgs(1),
+		Version:           version,
 	}
 
 	rootCmd.SetOut(out)
+	rootCmd.AddCommand(newWatchCmd(r))
 
 	rootCmd.PersistentFlags().BoolP(flagAutoAssign, "a", false, `Use values from environment variables TF_VAR_* and
 variable definitions files e.g. terraform.tfvars[.json] *.auto.tfvars[.json]`)
 	rootCmd.PersistentFlags().BoolP(flagDebug, "d", false, "Print debug log on stderr")
-	rootCmd.PersistentFlags().BoolP(flagEnvVar, "e", false, "Print output in export TF_VAR_image_id=ami-abc123 format")
-	rootCmd.PersistentFlags().BoolP(flagResource, "r", false, "Print output in Terraform Enterprise (tfe) provider's tfe_variable resource format")
-	rootCmd.PersistentFlags().BoolP(flagWorkspace, "w", false, "Print output variables as payloads for Workspace Variables API")
+	rootCmd.PersistentFlags().BoolP(flagEnvVar, "e", false, "Print output in export TF_VAR_image_id=ami-abc123 format, same as --format "+tfvar.FormatEnvVars)
+	rootCmd.PersistentFlags().BoolP(flagResource, "r", false, "Print output in Terraform Enterprise (tfe) provider's tfe_variable resource format, same as --format "+tfvar.FormatTFEResource)
+	rootCmd.PersistentFlags().BoolP(flagWorkspace, "w", false, "Print output variables as payloads for Workspace Variables API, same as --format "+tfvar.FormatWorkspacePayload)
+	rootCmd.PersistentFlags().StringP(flagFormat, "f", tfvar.FormatTFVars, "Print output in the given format, one of: "+strings.Join(tfvar.Formats(), ", "))
+	rootCmd.PersistentFlags().String(flagModule, "", `Use the variables of the module installed by terraform init with the given key
+in .terraform/modules/modules.json, e.g. vpc or vpc.subnets`)
 	rootCmd.PersistentFlags().Bool(flagNoDefault, false, "Do not use defined default values")
-	rootCmd.PersistentFlags().StringArray(flagVar, []string{}, `Set a variable in the generated definitions.
-This flag can be set multiple times.`)
-	rootCmd.PersistentFlags().StringArray(flagVarFile, []string{}, `Set variables from a file.
+	rootCmd.PersistentFlags().StringP(flagOutput, "o", "", `Write output to the given file instead of stdout. The path may contain
+placeholders {{dir}} (module directory), {{base}} (last element of
+the module directory), and {{format}}, e.g. {{dir}}/{{format}}.tfvars`)
+	rootCmd.PersistentFlags().String(flagOutputDir, "", `Write output to files under the given directory, named after --output
+(default "`+defaultOutputPattern+`")`)
+	rootCmd.PersistentFlags().Bool(flagForce, false, "Overwrite existing files written by --output and --output-dir")
+	rootCmd.PersistentFlags().Int(flagParallel, 10, "Limit the number of modules processed concurrently when given multiple directories")
+	rootCmd.PersistentFlags().String(flagReport, "", `Write a JSON report of the variables without value of each module to the given file
+when given multiple directories`)
+	rootCmd.PersistentFlags().String(flagTFCLIArgs, "", `Use -var and -var-file options from environment variables TF_CLI_ARGS and
+TF_CLI_ARGS_<command> as terraform <command> would (default command "plan").
+Relative -var-file paths are resolved from DIR`)
+	rootCmd.PersistentFlags().Lookup(flagTFCLIArgs).NoOptDefVal = "plan"
+	rootCmd.PersistentFlags().String(flagFromOutput, "", `Use the outputs read from the output of terraform output -json or a local state file
+(.tfstate) as the values of the variables with the same names, or as given by --outputs-mapping.
+The values take precedence over --auto-assign and --tf-cli-args`)
+	rootCmd.PersistentFlags().String(flagOutputMap, "", `Map variables to the outputs of --from-outputs with the given file, one
+"variable <- output" per line, e.g. vpc_id <- network.vpc_id`)
+	rootCmd.PersistentFlags().String(flagFromPlan, "", `Use the values of the variables a saved plan was made with, read from its JSON
+representation output by terraform show -json. The values take precedence over
+--auto-assign and --tf-cli-args, but not over --var and --var-file`)
+	rootCmd.PersistentFlags().Var(newVarArgsValue(tfvar.VarArgVar, &r.varArgs), flagVar, `Set a variable in the generated definitions.
 This flag can be set multiple times.`)
+	rootCmd.PersistentFlags().Var(newVarArgsValue(tfvar.VarArgVarFile, &r.varArgs), flagVarFile, `Set variables from a file.
+This flag can be set multiple times. --var and --var-file are
+applied in the order they are given, later ones take precedence.
+Use --var-file - to read the variables from the standard input`)
+	rootCmd.PersistentFlags().Bool(flagAllowFuncs, false, `Allow variable definitions files to call functions, e.g. jsondecode(file("data.json")).
+Only the string, collection, encoding, and type conversion functions listed in
+the README, file, and env are available`)
+	rootCmd.PersistentFlags().String(flagStdinFmt, tfvar.VarFileFormatAuto, `Format of the variables read by --var-file -, one of: auto, hcl, json.
+auto detects JSON by its leading '{'`)
+	rootCmd.PersistentFlags().String(flagSync, "", `Update the given variable definitions file in place instead of printing,
+keeping existing values and comments. For a Terraform test file (.tftest.hcl),
+the required variables missing from its file level variables block are added`)
+	rootCmd.PersistentFlags().Bool(flagDryRun, false, "Print the changes --sync would make as a diff without writing the file")
+	rootCmd.PersistentFlags().String(flagTemplate, "", `Print output using the given Go text/template file, executed with
+the list of variables sorted by name`)
+	rootCmd.PersistentFlags().Bool(flagPrune, false, `Remove attributes that match no declared variable when using --sync.
+They are only reported in Terraform test files (.tftest.hcl), where run blocks may test other modules`)
+	rootCmd.PersistentFlags().Bool(flagTofu, false, `Follow OpenTofu's rules to select the configuration files, i.e. .tofu and .tofu.json
+files take precedence over .tf and .tf.json files of the same name. By default the rules
+are followed if DIR contains any .tofu or .tofu.json file, use --tofu=false to ignore them`)
+	rootCmd.PersistentFlags().Bool(flagLenient, false, `Generate the variables even if the configurations have errors outside of
+variable declarations, e.g. in a resource block, printing them as warnings`)
+	rootCmd.PersistentFlags().Bool(flagJSONDiags, false, `Print errors and warnings on stderr as JSON diagnostics in the format of
+terraform validate -json instead of text. With multiple directories, the
+diagnostics of each module are keyed by its directory`)
 
 	return rootCmd, func() {
 		if r.log != nil {
@@ -69,19 +151,31 @@ This flag can be set multiple times.`)
 type runner struct {
 	out io.Writer
 	log *zap.SugaredLogger
+
+	// varArgs are the --var and --var-file options in command line order.
+	varArgs []tfvar.VarArg
+	// stdin is the content of the standard input, read once for --var-file -
+	// so that it can be used for every module that is loaded.
+	stdin []byte
+	// jsonDiags is true when errors and warnings are printed as JSON diagnostics instead of logged.
+	jsonDiags bool
+	// warnings are the warnings of the module loaded when given a single directory.
+	warnings []tfvar.Warning
 }
 
 func (r *runner) preRootRunE(cmd *cobra.Command, args []string) error {
 	// Setup logger
 	logConfig := zap.NewDevelopmentConfig()
 
-	isDebug, err := cmd.PersistentFlags().GetBool(flagDebug)
+	isDebug, err := cmd.Flags().GetBool(flagDebug)
 	if err != nil {
 		return errors.Wrap(err, "cmd: get flag --debug")
 	}
 
 	if !isDebug {
 		logConfig.Level = zap.NewAtomicLevelAt(zap.InfoLevel)
+		// Warnings are meant for users, not for debugging tfvar itself.
+		logConfig.DisableStacktrace = true
 	}
 
 	logger, err := logConfig.Build()
@@ -92,108 +186,390 @@ func (r *runner) preRootRunE(cmd *cobra.Command, args []string) error {
 	r.log = logger.Sugar()
 	r.log.Debug("Logger initialized")
 
+	r.jsonDiags, err = cmd.Flags().GetBool(flagJSONDiags)
+	if err != nil {
+		return errors.Wrap(err, "cmd: get flag --json-diagnostics")
+	}
+
 	return nil
 }
 
 func (r *runner) rootRunE(cmd *cobra.Command, args []string) error {
-	dir := args[0]
+	writer, format, err := r.writer(cmd)
+	if err != nil {
+		return err
+	}
+
+	output, err := r.outputOptions(cmd)
+	if err != nil {
+		return err
+	}
 
-	vars, err := tfvar.Load(dir)
+	opts, err := r.loadOptions(cmd)
 	if err != nil {
 		return err
 	}
 
-	sort.Slice(vars, func(i, j int) bool { return vars[i].Name < vars[j].Name })
+	syncFile, err := cmd.Flags().GetString(flagSync)
+	if err != nil {
+		return errors.Wrap(err, "cmd: get flag --sync")
+	}
+
+	if syncFile != "" {
+		if err := checkSyncFlags(cmd); err != nil {
+			return err
+		}
+	}
+
+	dirs, isBatch, err := expandDirs(args)
+	if err != nil {
+		return err
+	}
+
+	if isBatch {
+		if syncFile != "" {
+			return errors.New("cmd: --sync cannot be used with multiple directories")
+		}
+
+		return r.batch(cmd, dirs, opts, output, format, writer)
+	}
+
+	vars, warnings, err := r.load(cmd.Context(), dirs[0], opts)
+	if err != nil {
+		return err
+	}
+
+	r.warnings = warnings
+
+	if syncFile != "" {
+		return r.sync(cmd, syncFile, vars)
+	}
+
+	if output.pattern != "" {
+		return r.writeOutput(output, dirs[0], format, writer, vars)
+	}
+
+	return writer.Write(r.out, vars)
+}
+
+// checkSyncFlags returns an error if the flags that select the format or the destination of the output
+// are used with --sync, which updates a file of its own format in place.
+func checkSyncFlags(cmd *cobra.Command) error {
+	var selected []string
+
+	for _, flag := range []string{flagFormat, flagTemplate, flagEnvVar, flagResource, flagWorkspace, flagOutput, flagOutputDir} {
+		if cmd.Flags().Changed(flag) {
+			selected = append(selected, "--"+flag)
+		}
+	}
+
+	if len(selected) > 0 {
+		return errors.Errorf("cmd: %s cannot be used together", strings.Join(append(selected, "--"+flagSync), ", "))
+	}
+
+	return nil
+}
+
+// loadOptions returns the tfvar.Options, apart from the directory, selected by the flags
+// that control how the variables of a module are loaded and assigned.
+func (r *runner) loadOptions(cmd *cobra.Command) (tfvar.Options, error) {
+	var (
+		opts tfvar.Options
+		err  error
+	)
 
-	ignoreDefault, err := cmd.PersistentFlags().GetBool(flagNoDefault)
+	opts.Module, err = cmd.Flags().GetString(flagModule)
 	if err != nil {
-		return errors.Wrap(err, "cmd: get flag --ignore-default")
+		return opts, errors.Wrap(err, "cmd: get flag --module")
 	}
 
-	if ignoreDefault {
-		r.log.Debug("Replacing values with null")
-		for i, v := range vars {
-			vars[i].Value = cty.NullVal(v.Value.Type())
+	opts.IgnoreDefault, err = cmd.Flags().GetBool(flagNoDefault)
+	if err != nil {
+		return opts, errors.Wrap(err, "cmd: get flag --ignore-default")
+	}
+
+	if cmd.Flags().Changed(flagTofu) {
+		tofu, err := cmd.Flags().GetBool(flagTofu)
+		if err != nil {
+			return opts, errors.Wrap(err, "cmd: get flag --tofu")
 		}
+
+		opts.Tofu = &tofu
 	}
 
-	isEnvVar, err := cmd.PersistentFlags().GetBool(flagEnvVar)
+	opts.Lenient, err = cmd.Flags().GetBool(flagLenient)
 	if err != nil {
-		return errors.Wrap(err, "cmd: get flag --env-var")
+		return opts, errors.Wrap(err, "cmd: get flag --lenient")
 	}
 
-	isAutoAssign, err := cmd.PersistentFlags().GetBool(flagAutoAssign)
+	opts.AutoAssign, err = cmd.Flags().GetBool(flagAutoAssign)
 	if err != nil {
-		return errors.Wrap(err, "cmd: get flag --auto-assign")
+		return opts, errors.Wrap(err, "cmd: get flag --auto-assign")
 	}
 
-	isWorkspace, err := cmd.PersistentFlags().GetBool(flagWorkspace)
+	opts.TFCLICommand, err = cmd.Flags().GetString(flagTFCLIArgs)
 	if err != nil {
-		return errors.Wrap(err, "cmd: get flag --workspace")
+		return opts, errors.Wrap(err, "cmd: get flag --tf-cli-args")
 	}
 
-	isResource, err := cmd.PersistentFlags().GetBool(flagResource)
+	opts.OutputsFile, err = cmd.Flags().GetString(flagFromOutput)
 	if err != nil {
-		return errors.Wrap(err, "cmd: get flag --resource")
+		return opts, errors.Wrap(err, "cmd: get flag --from-outputs")
 	}
 
-	unparseds := make(map[string]tfvar.UnparsedVariableValue)
+	opts.OutputMappingFile, err = cmd.Flags().GetString(flagOutputMap)
+	if err != nil {
+		return opts, errors.Wrap(err, "cmd: get flag --outputs-mapping")
+	}
 
-	if isAutoAssign {
-		r.log.Debug("Collecting values from environment variables")
-		tfvar.CollectFromEnvVars(unparseds)
+	if opts.OutputMappingFile != "" && opts.OutputsFile == "" {
+		return opts, errors.New("cmd: --outputs-mapping requires --from-outputs")
+	}
 
-		autoFiles := tfvar.LookupTFVarsFiles(dir)
+	opts.PlanFile, err = cmd.Flags().GetString(flagFromPlan)
+	if err != nil {
+		return opts, errors.Wrap(err, "cmd: get flag --from-plan")
+	}
+
+	opts.VarArgs = r.varArgs
+
+	opts.StdinFormat, err = cmd.Flags().GetString(flagStdinFmt)
+	if err != nil {
+		return opts, errors.Wrap(err, "cmd: get flag --stdin-format")
+	}
 
-		for _, f := range autoFiles {
-			if err := tfvar.CollectFromFile(f, unparseds); err != nil {
-				return err
+	opts.AllowFunctions, err = cmd.Flags().GetBool(flagAllowFuncs)
+	if err != nil {
+		return opts, errors.Wrap(err, "cmd: get flag --allow-functions")
+	}
+
+	for _, a := range r.varArgs {
+		if a.Kind == tfvar.VarArgVarFile && a.Value == tfvar.StdinVarFile && r.stdin == nil {
+			r.stdin, err = ioutil.ReadAll(cmd.InOrStdin())
+			if err != nil {
+				return opts, errors.Wrap(err, "cmd: reading standard input")
 			}
 		}
 	}
 
-	fvs, err := cmd.PersistentFlags().GetStringArray(flagVar)
+	return opts, nil
+}
+
+// load returns the variables of the module in dir, sorted by name, with the values
+// assigned from the sources selected in opts, and the warnings found loading them.
+// The warnings are logged unless they are printed as JSON diagnostics.
+func (r *runner) load(ctx context.Context, dir string, opts tfvar.Options) ([]tfvar.Variable, []tfvar.Warning, error) {
+	opts.Dir = dir
+
+	if r.stdin != nil {
+		opts.Stdin = bytes.NewReader(r.stdin)
+	}
+
+	r.log.Debugf("Loading %s", dir)
+
+	res, err := tfvar.Generate(ctx, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !r.jsonDiags {
+		for _, w := range res.Warnings {
+			r.log.Warnf("%s: %s", dir, w)
+		}
+	}
+
+	return res.Variables, res.Warnings, nil
+}
+
+// writer returns the tfvar.Writer selected by --format, --template, or one of
+// the format shorthand flags, and the name of the format. Only one of them can
+// be used at a time.
+func (r *runner) writer(cmd *cobra.Command) (tfvar.Writer, string, error) {
+	format, err := cmd.Flags().GetString(flagFormat)
 	if err != nil {
-		return errors.Wrap(err, "cmd: get flag --var")
+		return nil, "", errors.Wrap(err, "cmd: get flag --format")
 	}
 
-	for _, fv := range fvs {
-		if err := tfvar.CollectFromString(fv, unparseds); err != nil {
-			return err
+	var selected []string
+
+	if cmd.Flags().Changed(flagFormat) {
+		selected = append(selected, "--"+flagFormat)
+	}
+
+	shorthands := []struct {
+		flag   string
+		format string
+	}{
+		{flag: flagEnvVar, format: tfvar.FormatEnvVars},
+		{flag: flagResource, format: tfvar.FormatTFEResource},
+		{flag: flagWorkspace, format: tfvar.FormatWorkspacePayload},
+	}
+
+	for _, s := range shorthands {
+		isSet, err := cmd.Flags().GetBool(s.flag)
+		if err != nil {
+			return nil, "", errors.Wrapf(err, "cmd: get flag --%s", s.flag)
+		}
+
+		if isSet {
+			selected = append(selected, "--"+s.flag)
+			format = s.format
 		}
 	}
 
-	fromFiles, err := cmd.PersistentFlags().GetStringArray(flagVarFile)
+	templateFile, err := cmd.Flags().GetString(flagTemplate)
 	if err != nil {
-		return errors.Wrap(err, "cmd: get flag --var-file")
+		return nil, "", errors.Wrap(err, "cmd: get flag --template")
 	}
 
-	for _, fv := range fromFiles {
-		if err := tfvar.CollectFromFile(fv, unparseds); err != nil {
-			return err
+	if templateFile != "" {
+		selected = append(selected, "--"+flagTemplate)
+	}
+
+	if len(selected) > 1 {
+		return nil, "", errors.Errorf("cmd: %s cannot be used together", strings.Join(selected, ", "))
+	}
+
+	if templateFile != "" {
+		r.log.Debugf("Print outputs using template %s", templateFile)
+
+		text, err := ioutil.ReadFile(templateFile)
+		if err != nil {
+			return nil, "", errors.Wrapf(err, "cmd: reading file '%s'", templateFile)
+		}
+
+		tmpl, err := tfvar.NewTemplate(filepath.Base(templateFile), string(text))
+		if err != nil {
+			return nil, "", err
+		}
+
+		name := strings.TrimSuffix(filepath.Base(templateFile), filepath.Ext(templateFile))
+
+		return tfvar.NewTemplateWriter(tmpl), name, nil
+	}
+
+	r.log.Debugf("Print outputs in %s format", format)
+
+	writer, err := tfvar.LookupWriter(format)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if tfvar.IsPersistentFormat(format) {
+		writer = r.warnEphemeral(writer)
+	}
+
+	return writer, format, nil
+}
+
+// warnEphemeral wraps writer, which skips ephemeral variables, to warn about the skipped ones.
+func (r *runner) warnEphemeral(writer tfvar.Writer) tfvar.Writer {
+	return tfvar.WriterFunc(func(w io.Writer, vars []tfvar.Variable) error {
+		for _, v := range vars {
+			if v.Ephemeral {
+				r.log.Warnf("Skipping ephemeral variable %s, use --format %s to set its value", v.Name, tfvar.FormatEnvVars)
+			}
 		}
+
+		return writer.Write(w, vars)
+	})
+}
+
+func (r *runner) sync(cmd *cobra.Command, filename string, vars []tfvar.Variable) error {
+	isDryRun, err := cmd.Flags().GetBool(flagDryRun)
+	if err != nil {
+		return errors.Wrap(err, "cmd: get flag --dry-run")
 	}
 
-	vars, err = tfvar.ParseValues(unparseds, vars)
+	isPrune, err := cmd.Flags().GetBool(flagPrune)
+	if err != nil {
+		return errors.Wrap(err, "cmd: get flag --prune")
+	}
+
+	// New files that contain sensitive variables are only readable by the current user, as with --output.
+	perm := os.FileMode(0644)
+
+	for _, v := range vars {
+		if v.Sensitive {
+			perm = 0600
+			break
+		}
+	}
+
+	src, err := ioutil.ReadFile(filename)
+	switch {
+	case os.IsNotExist(err):
+		r.log.Debugf("Creating new file %s", filename)
+	case err != nil:
+		return errors.Wrapf(err, "cmd: reading file '%s'", filename)
+	default:
+		if info, err := os.Stat(filename); err == nil {
+			perm = info.Mode().Perm()
+		}
+	}
+
+	sync := tfvar.Sync
+	if tfvar.IsTFTestFile(filename) {
+		sync = tfvar.SyncTFTest
+	}
+
+	updated, result, err := sync(src, filename, vars, isPrune)
 	if err != nil {
 		return err
 	}
 
-	writer := tfvar.WriteAsTFVars
+	for _, name := range result.Added {
+		r.log.Debugf("Adding variable %s", name)
+	}
 
-	if isEnvVar {
-		r.log.Debug("Print outputs in environment variables format")
-		writer = tfvar.WriteAsEnvVars
+	for _, name := range result.Ephemeral {
+		r.log.Warnf("Skipping ephemeral variable %s, its value must not be stored in %s", name, filename)
 	}
 
-	if isWorkspace {
-		r.log.Debug("Print outputs in Workspace API payload format")
-		writer = tfvar.WriteAsWorkspacePayload
+	for _, name := range result.StoredEphemeral {
+		if isPrune {
+			r.log.Infof("Removing ephemeral variable %s: its value must not be stored", name)
+		} else {
+			r.log.Warnf("%s in %s is ephemeral, its value should not be stored, use --prune to remove it", name, filename)
+		}
 	}
 
-	if isResource {
-		r.log.Debug("Print outputs in tfe_resource format")
-		writer = tfvar.WriteAsTFEResource
+	for _, name := range result.Unknown {
+		if result.Removed {
+			r.log.Infof("Removing %s: no matching variable declaration", name)
+		} else {
+			r.log.Warnf("%s in %s does not match any variable declaration", name, filename)
+		}
+	}
+
+	if isDryRun {
+		diff := difflib.UnifiedDiff{
+			A:        splitLines(src),
+			B:        splitLines(updated),
+			FromFile: filename,
+			ToFile:   filename,
+			Context:  3,
+		}
+
+		return errors.Wrap(difflib.WriteUnifiedDiff(r.out, diff), "cmd: failed to write diff")
 	}
-	return writer(r.out, vars)
+
+	return writeFileAtomic(filename, updated, perm)
+}
+
+// splitLines is like difflib.SplitLines but does not produce an extra empty line
+// when b already ends with a newline.
+func splitLines(b []byte) []string {
+	lines := strings.SplitAfter(string(b), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	for i, l := range lines {
+		if !strings.HasSuffix(l, "\n") {
+			lines[i] = l + "\n"
+		}
+	}
+
+	return lines
 }
@@ -2,9 +2,15 @@ package cmd
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/sebdah/goldie/v2"
 	"github.com/stretchr/testify/assert"
@@ -206,3 +212,821 @@ func TestVarFileError(t *testing.T) {
 	assert.Error(t, cmd.Execute())
 	assert.Contains(t, actual.String(), `Error: tfvar: failed to parse 'testdata/bad.tfvars'`)
 }
+
+func TestConfigError(t *testing.T) {
+	os.Args = strings.Fields("tfvar ../pkg/tfvar/testdata/bad")
+
+	var actual bytes.Buffer
+	cmd, sync := New(&actual, "dev")
+	defer sync()
+
+	assert.Error(t, cmd.Execute())
+	assert.Contains(t, actual.String(), `Error: tfvar: loading config
+
+Error: Invalid block definition
+
+  on ../pkg/tfvar/testdata/bad/main.tf line 1:
+   1: variable "resource_name"
+`)
+}
+
+func TestLenient(t *testing.T) {
+	os.Args = strings.Fields("tfvar ../pkg/tfvar/testdata/lenient")
+
+	var actual bytes.Buffer
+	cmd, sync := New(&actual, "dev")
+	defer sync()
+
+	assert.Error(t, cmd.Execute())
+
+	os.Args = strings.Fields("tfvar ../pkg/tfvar/testdata/lenient --lenient")
+
+	actual.Reset()
+	cmd, sync = New(&actual, "dev")
+	defer sync()
+
+	assert.NoError(t, cmd.Execute())
+	assert.Equal(t, `image_id      = null
+instance_type = "t3.micro"
+region        = "us-east-1"
+`, actual.String())
+}
+
+func TestEphemeral(t *testing.T) {
+	tests := []struct {
+		name string
+		args string
+		want string
+	}{
+		{
+			name: "tfvars",
+			args: "tfvar testdata/ephemeral",
+			want: `region = "ap-northeast-1"
+`,
+		},
+		{
+			name: "env",
+			args: "tfvar testdata/ephemeral -e",
+			want: `export TF_VAR_db_password=''
+export TF_VAR_region='ap-northeast-1'
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			os.Args = strings.Fields(tt.args)
+
+			var actual bytes.Buffer
+			cmd, sync := New(&actual, "dev")
+			defer sync()
+
+			assert.NoError(t, cmd.Execute())
+			assert.Equal(t, tt.want, actual.String())
+		})
+	}
+}
+
+func TestTofu(t *testing.T) {
+	tests := []struct {
+		name string
+		args string
+		want string
+	}{
+		{
+			name: "auto",
+			args: "tfvar ../pkg/tfvar/testdata/tofu",
+			want: `region = "eu-central-1"
+zone   = null
+`,
+		},
+		{
+			name: "off",
+			args: "tfvar ../pkg/tfvar/testdata/tofu --tofu=false",
+			want: `region = "us-east-1"
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			os.Args = strings.Fields(tt.args)
+
+			var actual bytes.Buffer
+			cmd, sync := New(&actual, "dev")
+			defer sync()
+
+			assert.NoError(t, cmd.Execute())
+			assert.Equal(t, tt.want, actual.String())
+		})
+	}
+}
+
+func TestJSONDiagnostics(t *testing.T) {
+	os.Args = strings.Fields("tfvar ../pkg/tfvar/testdata/bad --json-diagnostics")
+
+	var actual, stderr bytes.Buffer
+	cmd, sync := New(&actual, "dev")
+	defer sync()
+
+	cmd.SetErr(&stderr)
+
+	assert.Error(t, cmd.Execute())
+	assert.Empty(t, actual.String())
+	assert.JSONEq(t, `{
+  "format_version": "1.0",
+  "valid": false,
+  "error_count": 1,
+  "warning_count": 0,
+  "diagnostics": [
+    {
+      "severity": "error",
+      "summary": "Invalid block definition",
+      "detail": "A block definition must have block content delimited by \"{\" and \"}\", starting on the same line as the block header.",
+      "range": {
+        "filename": "../pkg/tfvar/testdata/bad/main.tf",
+        "start": {"line": 1, "column": 25, "byte": 24},
+        "end": {"line": 2, "column": 1, "byte": 25}
+      }
+    }
+  ]
+}`, stderr.String())
+}
+
+func TestJSONDiagnosticsWarnings(t *testing.T) {
+	os.Args = strings.Fields("tfvar ../pkg/tfvar/testdata/lenient --lenient --json-diagnostics")
+
+	var actual, stderr bytes.Buffer
+	cmd, sync := New(&actual, "dev")
+	defer sync()
+
+	cmd.SetErr(&stderr)
+
+	require.NoError(t, cmd.Execute())
+	assert.NotEmpty(t, actual.String())
+
+	var got map[string]interface{}
+	require.NoError(t, json.Unmarshal(stderr.Bytes(), &got))
+	assert.Equal(t, true, got["valid"])
+	assert.Equal(t, float64(1), got["warning_count"])
+	assert.Equal(t, "warning", got["diagnostics"].([]interface{})[0].(map[string]interface{})["severity"])
+}
+
+func TestJSONDiagnosticsBatch(t *testing.T) {
+	os.Args = strings.Fields("tfvar testdata/live/... --json-diagnostics")
+
+	var actual, stderr bytes.Buffer
+	cmd, sync := New(&actual, "dev")
+	defer sync()
+
+	cmd.SetErr(&stderr)
+
+	assert.Error(t, cmd.Execute())
+	assert.NotContains(t, actual.String(), "Error:")
+
+	var got struct {
+		Valid      bool `json:"valid"`
+		ErrorCount int  `json:"error_count"`
+		Modules    map[string]struct {
+			Valid       bool `json:"valid"`
+			Diagnostics []struct {
+				Summary string `json:"summary"`
+			} `json:"diagnostics"`
+		} `json:"modules"`
+	}
+
+	require.NoError(t, json.Unmarshal(stderr.Bytes(), &got))
+	assert.False(t, got.Valid)
+	assert.Equal(t, 1, got.ErrorCount)
+	assert.Len(t, got.Modules, 3)
+	assert.True(t, got.Modules["testdata/live/network"].Valid)
+	assert.False(t, got.Modules["testdata/live/broken"].Valid)
+	assert.Equal(t, "Unclosed configuration block", got.Modules["testdata/live/broken"].Diagnostics[0].Summary)
+}
+
+func TestSyncDryRun(t *testing.T) {
+	os.Args = strings.Fields("tfvar testdata --sync testdata/my.tfvars --dry-run")
+
+	var actual bytes.Buffer
+	cmd, sync := New(&actual, "dev")
+	defer sync()
+
+	require.NoError(t, cmd.Execute())
+	assert.Equal(t, `--- testdata/my.tfvars
++++ testdata/my.tfvars
+@@ -1 +1,8 @@
+-image_id = "xyz"
++image_id                = "xyz"
++availability_zone_names = ["us-west-1a"]
++docker_ports = [{
++  external = 8300
++  internal = 8300
++  protocol = "tcp"
++}]
++password = null
+`, actual.String())
+}
+
+func TestSyncSensitive(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "new.tfvars")
+
+	os.Args = []string{"tfvar", "testdata", "--sync", filename}
+
+	var actual bytes.Buffer
+	cmd, sync := New(&actual, "dev")
+	defer sync()
+
+	require.NoError(t, cmd.Execute())
+
+	info, err := os.Stat(filename)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm(), "password is sensitive")
+}
+
+func TestSyncTFTest(t *testing.T) {
+	os.Args = strings.Fields("tfvar testdata --sync testdata/main.tftest.hcl --dry-run")
+
+	var actual bytes.Buffer
+	cmd, sync := New(&actual, "dev")
+	defer sync()
+
+	require.NoError(t, cmd.Execute())
+	assert.Equal(t, `--- testdata/main.tftest.hcl
++++ testdata/main.tftest.hcl
+@@ -1,3 +1,8 @@
++variables {
++  image_id = null
++  password = null
++}
++
+ run "plan" {
+   command = plan
+ }
+`, actual.String())
+}
+
+func TestTemplate(t *testing.T) {
+	os.Args = strings.Fields("tfvar testdata --template testdata/markdown.tmpl")
+
+	var actual bytes.Buffer
+	cmd, sync := New(&actual, "dev")
+	defer sync()
+
+	require.NoError(t, cmd.Execute())
+	assert.Equal(t, `| Name | Type | Value |
+|------|------|-------|
+| availability_zone_names | list(string) | ["us-west-1a"] |
+| docker_ports | list(object({external=number,internal=number,protocol=string})) | [{"external":8300,"internal":8300,"protocol":"tcp"}] |
+| image_id | string | null |
+| password | string | (sensitive) |
+`, actual.String())
+}
+
+func TestFormat(t *testing.T) {
+	os.Args = strings.Fields("tfvar testdata --format env")
+
+	var actual bytes.Buffer
+	cmd, sync := New(&actual, "dev")
+	defer sync()
+
+	require.NoError(t, cmd.Execute())
+	assert.Equal(t, `export TF_VAR_availability_zone_names='["us-west-1a"]'
+export TF_VAR_docker_ports='[{ external = 8300, internal = 8300, protocol = "tcp" }]'
+export TF_VAR_image_id=''
+export TF_VAR_password=''
+`, actual.String())
+}
+
+func TestVarFileStdin(t *testing.T) {
+	os.Args = strings.Fields("tfvar testdata --var-file - --stdin-format json -e")
+
+	var actual bytes.Buffer
+	cmd, sync := New(&actual, "dev")
+	defer sync()
+
+	cmd.SetIn(strings.NewReader(`{"image_id": "ami-abc123", "password": "secret"}`))
+
+	require.NoError(t, cmd.Execute())
+	assert.Equal(t, `export TF_VAR_availability_zone_names='["us-west-1a"]'
+export TF_VAR_docker_ports='[{ external = 8300, internal = 8300, protocol = "tcp" }]'
+export TF_VAR_image_id='ami-abc123'
+export TF_VAR_password='secret'
+`, actual.String())
+}
+
+func TestFromPlan(t *testing.T) {
+	os.Args = strings.Fields("tfvar testdata --from-plan testdata/plan.json --var password=secret")
+
+	var actual bytes.Buffer
+	cmd, sync := New(&actual, "dev")
+	defer sync()
+
+	require.NoError(t, cmd.Execute())
+	assert.Equal(t, `availability_zone_names = ["us-west-1a"]
+docker_ports = [{
+  external = 8300
+  internal = 8300
+  protocol = "tcp"
+}]
+image_id = "ami-abc123"
+password = "secret"
+`, actual.String())
+}
+
+func TestFromOutputs(t *testing.T) {
+	os.Args = strings.Fields("tfvar testdata --from-outputs testdata/outputs.json --outputs-mapping testdata/outputs-mapping.txt")
+
+	var actual bytes.Buffer
+	cmd, sync := New(&actual, "dev")
+	defer sync()
+
+	require.NoError(t, cmd.Execute())
+	assert.Equal(t, `availability_zone_names = ["ap-northeast-1a"]
+docker_ports = [{
+  external = 8300
+  internal = 8300
+  protocol = "tcp"
+}]
+image_id = "ami-abc123"
+password = null
+`, actual.String())
+}
+
+func TestAllowFunctions(t *testing.T) {
+	unsetImage := setenv(t, "TFVAR_TEST_IMAGE", "ami-abc123")
+	defer unsetImage()
+
+	os.Args = strings.Fields("tfvar testdata --var-file testdata/functions.tfvars --allow-functions")
+
+	var actual bytes.Buffer
+	cmd, sync := New(&actual, "dev")
+	defer sync()
+
+	require.NoError(t, cmd.Execute())
+	assert.Equal(t, `availability_zone_names = ["ap-northeast-1a", "ap-northeast-1c"]
+docker_ports = [{
+  external = 80
+  internal = 8080
+  protocol = "tcp"
+}]
+image_id = "AMI-ABC123"
+password = "c2VjcmV0"
+`, actual.String())
+
+	os.Args = strings.Fields("tfvar testdata --var-file testdata/functions.tfvars")
+
+	cmd, sync = New(&actual, "dev")
+	defer sync()
+
+	cmd.SilenceErrors = true
+	cmd.SilenceUsage = true
+
+	err := cmd.Execute()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Function calls not allowed")
+}
+
+func TestFormatError(t *testing.T) {
+	tests := []struct {
+		name string
+		args string
+		want string
+	}{
+		{
+			name: "unknown format",
+			args: "tfvar testdata --format yaml",
+			want: `Error: tfvar: unknown format 'yaml', must be one of: env, provenance, resource, tftest, tfvars, workspace`,
+		},
+		{
+			name: "multiple formats",
+			args: "tfvar testdata -e -w -r",
+			want: `Error: cmd: --env-var, --resource, --workspace cannot be used together`,
+		},
+		{
+			name: "format and template",
+			args: "tfvar testdata --format env --template testdata/markdown.tmpl",
+			want: `Error: cmd: --format, --template cannot be used together`,
+		},
+		{
+			name: "sync and format",
+			args: "tfvar testdata --sync testdata/my.tfvars -e",
+			want: `Error: cmd: --env-var, --sync cannot be used together`,
+		},
+		{
+			name: "sync and output",
+			args: "tfvar testdata --sync testdata/my.tfvars --output out.tfvars --output-dir out",
+			want: `Error: cmd: --output, --output-dir, --sync cannot be used together`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Args = strings.Fields(tt.args)
+
+			var actual bytes.Buffer
+			cmd, sync := New(&actual, "dev")
+			defer sync()
+
+			assert.Error(t, cmd.Execute())
+			assert.Contains(t, actual.String(), tt.want)
+		})
+	}
+}
+
+func TestArchive(t *testing.T) {
+	os.Args = strings.Fields("tfvar testdata/modules.zip//modules/vpc -a")
+
+	var actual bytes.Buffer
+	cmd, sync := New(&actual, "dev")
+	defer sync()
+
+	require.NoError(t, cmd.Execute())
+	assert.Equal(t, `cidr_block = "10.0.0.0/16"
+`, actual.String())
+}
+
+func TestModule(t *testing.T) {
+	os.Args = strings.Fields("tfvar testdata/installed --module vpc")
+
+	var actual bytes.Buffer
+	cmd, sync := New(&actual, "dev")
+	defer sync()
+
+	require.NoError(t, cmd.Execute())
+	assert.Equal(t, `cidr = "10.0.0.0/16"
+name = null
+`, actual.String())
+}
+
+func TestTFCLIArgs(t *testing.T) {
+	unsetPlan := setenv(t, "TF_CLI_ARGS_plan", "-var-file=my.tfvars -var password=plan")
+	defer unsetPlan()
+
+	unsetAll := setenv(t, "TF_CLI_ARGS", "-no-color -var 'password=general'")
+	defer unsetAll()
+
+	unsetApply := setenv(t, "TF_CLI_ARGS_apply", "-var password=apply")
+	defer unsetApply()
+
+	tests := []struct {
+		name string
+		args string
+		want string
+	}{
+		{
+			name: "plan",
+			args: "tfvar testdata --tf-cli-args",
+			want: `image_id = "xyz"
+password = "general"
+`,
+		},
+		{
+			name: "apply",
+			args: "tfvar testdata --tf-cli-args=apply",
+			want: `image_id = null
+password = "general"
+`,
+		},
+		{
+			name: "command line wins",
+			args: "tfvar testdata --tf-cli-args --var password=cli",
+			want: `image_id = "xyz"
+password = "cli"
+`,
+		},
+		{
+			name: "disabled",
+			args: "tfvar testdata",
+			want: `image_id = null
+password = null
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Args = strings.Fields(tt.args)
+
+			var actual bytes.Buffer
+			cmd, sync := New(&actual, "dev")
+			defer sync()
+
+			require.NoError(t, cmd.Execute())
+			assert.Contains(t, actual.String(), tt.want)
+		})
+	}
+}
+
+func TestBatch(t *testing.T) {
+	report := filepath.Join(t.TempDir(), "report.json")
+	os.Args = []string{"tfvar", "testdata/live/...", "testdata/precedence", "--parallelism", "2", "--report", report}
+
+	var actual bytes.Buffer
+	cmd, sync := New(&actual, "dev")
+	defer sync()
+
+	assert.EqualError(t, cmd.Execute(), "cmd: 1 of 4 modules failed")
+	assert.Equal(t, `==> testdata/live/app/web <==
+image_id = null
+replicas = 2
+tag      = null
+
+==> testdata/live/network <==
+cidr = "10.0.0.0/16"
+
+==> testdata/precedence <==
+v1 = null
+v2 = null
+v3 = null
+v4 = null
+v5 = null
+v6 = null
+`, strings.SplitN(actual.String(), "Error:", 2)[0])
+
+	b, err := ioutil.ReadFile(report)
+	require.NoError(t, err)
+
+	var got map[string]interface{}
+	require.NoError(t, json.Unmarshal(b, &got))
+	assert.Equal(t, float64(7), got["missing"])
+	assert.Equal(t, float64(1), got["failed"])
+
+	modules := got["modules"].([]interface{})
+	require.Len(t, modules, 4)
+	assert.Equal(t, "testdata/live/app/web", modules[0].(map[string]interface{})["dir"])
+	assert.Equal(t, []interface{}{"image_id"}, modules[0].(map[string]interface{})["missing"], "tag defaults to null")
+	assert.Equal(t, "testdata/live/broken", modules[1].(map[string]interface{})["dir"])
+	assert.Contains(t, modules[1].(map[string]interface{})["error"], "tfvar: loading config")
+}
+
+func TestBatchError(t *testing.T) {
+	tests := []struct {
+		name string
+		args string
+		want string
+	}{
+		{
+			name: "no modules",
+			args: "tfvar testdata/live/docs/...",
+			want: "Error: cmd: no Terraform configuration found in testdata/live/docs/...",
+		},
+		{
+			name: "sync",
+			args: "tfvar testdata/live/network testdata/precedence --sync my.tfvars",
+			want: "Error: cmd: --sync cannot be used with multiple directories",
+		},
+		{
+			name: "parallelism",
+			args: "tfvar testdata/live/network testdata/precedence --parallelism 0",
+			want: "Error: cmd: --parallelism must be at least 1, got 0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Args = strings.Fields(tt.args)
+
+			var actual bytes.Buffer
+			cmd, sync := New(&actual, "dev")
+			defer sync()
+
+			assert.Error(t, cmd.Execute())
+			assert.Contains(t, actual.String(), tt.want)
+		})
+	}
+}
+
+func TestOutput(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "out", "{{base}}.{{format}}")
+	os.Args = []string{"tfvar", "testdata", "--output", output, "-e"}
+
+	var actual bytes.Buffer
+	cmd, sync := New(&actual, "dev")
+	defer sync()
+
+	require.NoError(t, cmd.Execute())
+	assert.Empty(t, actual.String())
+
+	filename := filepath.Join(filepath.Dir(output), "testdata.env")
+
+	b, err := ioutil.ReadFile(filename)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), "export TF_VAR_image_id=''\n")
+
+	info, err := os.Stat(filename)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm(), "password is sensitive")
+
+	// Existing files are not overwritten without --force.
+	os.Args = []string{"tfvar", "testdata", "--output", output, "-e"}
+	cmd, sync = New(&actual, "dev")
+	defer sync()
+
+	assert.Error(t, cmd.Execute())
+	assert.Contains(t, actual.String(), "already exists, use --force to overwrite")
+
+	os.Args = []string{"tfvar", "testdata", "--output", output, "-e", "--force", "--var", "image_id=abc"}
+	cmd, sync = New(&actual, "dev")
+	defer sync()
+
+	require.NoError(t, cmd.Execute())
+
+	b, err = ioutil.ReadFile(filename)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), "export TF_VAR_image_id='abc'\n")
+
+	matches, err := filepath.Glob(filepath.Join(filepath.Dir(output), ".*"))
+	require.NoError(t, err)
+	assert.Empty(t, matches, "no temporary file left")
+}
+
+func TestOutputDir(t *testing.T) {
+	outputDir := t.TempDir()
+	os.Args = []string{"tfvar", "testdata/live/network", "testdata/live/app/...", "--output-dir", outputDir}
+
+	var actual bytes.Buffer
+	cmd, sync := New(&actual, "dev")
+	defer sync()
+
+	require.NoError(t, cmd.Execute())
+	assert.Empty(t, actual.String())
+
+	b, err := ioutil.ReadFile(filepath.Join(outputDir, "testdata/live/network/tfvars.tfvars"))
+	require.NoError(t, err)
+	assert.Equal(t, "cidr = \"10.0.0.0/16\"\n", string(b))
+
+	info, err := os.Stat(filepath.Join(outputDir, "testdata/live/network/tfvars.tfvars"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+
+	b, err = ioutil.ReadFile(filepath.Join(outputDir, "testdata/live/app/web/tfvars.tfvars"))
+	require.NoError(t, err)
+	assert.Equal(t, "image_id = null\nreplicas = 2\ntag      = null\n", string(b))
+}
+
+func TestOutputSameFile(t *testing.T) {
+	os.Args = []string{"tfvar", "testdata/live/network", "testdata/precedence", "--output", filepath.Join(t.TempDir(), "out.tfvars")}
+
+	var actual bytes.Buffer
+	cmd, sync := New(&actual, "dev")
+	defer sync()
+
+	assert.Error(t, cmd.Execute())
+	assert.Contains(t, actual.String(), "Error: cmd: --output must contain {{dir}} or {{base}} with multiple directories")
+
+	// Both modules are named "web".
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "a", "web"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "b", "web"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a", "web", "main.tf"), []byte(`variable "a" {}`), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "b", "web", "main.tf"), []byte(`variable "b" {}`), 0644))
+
+	output := filepath.Join(dir, "out", "{{base}}.tfvars")
+	os.Args = []string{"tfvar", filepath.Join(dir, "a", "web"), filepath.Join(dir, "b", "web"), "--output", output}
+
+	cmd, sync = New(&actual, "dev")
+	defer sync()
+
+	assert.Error(t, cmd.Execute())
+	assert.Contains(t, actual.String(), "Error: cmd: --output gives the same file '"+filepath.Join(dir, "out", "web.tfvars")+"'")
+	assert.NoFileExists(t, filepath.Join(dir, "out", "web.tfvars"))
+}
+
+// syncBuffer is a bytes.Buffer that can be written and read concurrently.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "main.tf"), []byte(`variable "image_id" {}`), 0644))
+
+	os.Args = []string{"tfvar", "watch", dir, "--debounce", "10ms", "-a"}
+
+	var actual syncBuffer
+	cmd, sync := New(&actual, "dev")
+	defer sync()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+
+	go func() {
+		done <- cmd.ExecuteContext(ctx)
+	}()
+
+	assert.Eventually(t, func() bool {
+		return actual.String() == "image_id = null\n"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "terraform.tfvars"), []byte(`image_id = "abc"`), 0644))
+
+	assert.Eventually(t, func() bool {
+		return strings.HasSuffix(actual.String(), "image_id = \"abc\"\n")
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "main.tf"), []byte(`variable "image_id" {`), 0644))
+
+	assert.Eventually(t, func() bool {
+		return strings.Contains(actual.String(), "Error: tfvar: loading config")
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "main.tf"), []byte(`variable "ami" {}`), 0644))
+
+	assert.Eventually(t, func() bool {
+		return strings.HasSuffix(actual.String(), "ami = null\n")
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestWatchOutput(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "main.tf"), []byte(`variable "image_id" {}`), 0644))
+
+	output := filepath.Join(dir, "generated.auto.tfvars")
+	os.Args = []string{"tfvar", "watch", dir, "--debounce", "10ms", "--output", output}
+
+	var actual syncBuffer
+	cmd, sync := New(&actual, "dev")
+	defer sync()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+
+	go func() {
+		done <- cmd.ExecuteContext(ctx)
+	}()
+
+	var written os.FileInfo
+
+	require.Eventually(t, func() bool {
+		info, err := os.Stat(output)
+		written = info
+
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Writing the output must not trigger another regeneration.
+	time.Sleep(200 * time.Millisecond)
+
+	info, err := os.Stat(output)
+	require.NoError(t, err)
+	assert.True(t, os.SameFile(written, info), "output was rewritten")
+
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestWatchFormatConflict(t *testing.T) {
+	os.Args = []string{"tfvar", "watch", "testdata", "--format", "env", "--template", "testdata/markdown.tmpl"}
+
+	var actual bytes.Buffer
+	cmd, sync := New(&actual, "dev")
+	defer sync()
+
+	// Without the error, watch would run until the context is done.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	assert.Error(t, cmd.ExecuteContext(ctx))
+	assert.Contains(t, actual.String(), "Error: cmd: --format, --template cannot be used together")
+}
+
+func TestIsWatchedFile(t *testing.T) {
+	for name, want := range map[string]bool{
+		"main.tf":                true,
+		"main.tf.json":           true,
+		"override.tf":            true,
+		"x_override.tf.json":     true,
+		"terraform.tfvars":       true,
+		"terraform.tfvars.json":  true,
+		"my.auto.tfvars":         true,
+		"my.auto.tfvars.json":    true,
+		"my.tfvars":              false,
+		".main.tf.swp":           false,
+		"main.tf~":               false,
+		"README.md":              false,
+		".terraform.lock.hcl":    false,
+		"terraform.tfstate":      false,
+		"terraform.tfvars.swp":   false,
+		"other.terraform.tfvars": false,
+	} {
+		assert.Equal(t, want, isWatchedFile(name), name)
+	}
+}
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/mattn/go-isatty"
+	"github.com/shihanng/tfvar/pkg/tfvar"
+	"github.com/spf13/cobra"
+)
+
+// diagnosedError is an error whose HCL diagnostics have been rendered with source code snippets.
+type diagnosedError struct {
+	cause error
+	msg   string
+}
+
+func (e *diagnosedError) Error() string { return e.msg }
+func (e *diagnosedError) Unwrap() error { return e.cause }
+
+// reportedError is an error whose diagnostics have already been printed as JSON.
+type reportedError struct {
+	cause error
+}
+
+func (e *reportedError) Error() string { return e.cause.Error() }
+func (e *reportedError) Unwrap() error { return e.cause }
+
+// reportErrors wraps run so that its error and the warnings of the module are printed as JSON
+// diagnostics with --json-diagnostics, or the error with the source code snippets of its HCL
+// diagnostics otherwise. The error is still returned for a non-zero exit status.
+func (r *runner) reportErrors(run func(*cobra.Command, []string) error) func(*cobra.Command, []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		err := run(cmd, args)
+
+		if !r.jsonDiags {
+			if err == nil {
+				return nil
+			}
+
+			return r.diagnose(err)
+		}
+
+		if err != nil {
+			// The JSON diagnostics are the only output on failure.
+			cmd.SilenceErrors = true
+			cmd.SilenceUsage = true
+		}
+
+		var reported *reportedError
+		if errors.As(err, &reported) || (err == nil && len(r.warnings) == 0) {
+			return err
+		}
+
+		if werr := tfvar.WriteDiagnosticsJSON(cmd.ErrOrStderr(), err, r.warnings...); werr != nil {
+			return werr
+		}
+
+		return err
+	}
+}
+
+// diagnose renders the HCL diagnostics of err, if any, with the file, line, and highlighted
+// source code snippet of each of them, so that they are printed as Terraform does.
+// The output is colored when r.out is a terminal.
+func (r *runner) diagnose(err error) error {
+	var diagErr *tfvar.DiagnosticsError
+	if !errors.As(err, &diagErr) {
+		return err
+	}
+
+	var buf bytes.Buffer
+
+	buf.WriteString(diagErr.Summary)
+	buf.WriteString("\n\n")
+
+	if werr := diagErr.WriteDiagnostics(&buf, 0, isTerminal(r.out)); werr != nil {
+		return err
+	}
+
+	return &diagnosedError{
+		cause: err,
+		msg:   strings.TrimRight(buf.String(), "\n"),
+	}
+}
+
+// isTerminal reports whether w is a terminal that supports colors. Colors can be disabled
+// with the NO_COLOR environment variable, see https://no-color.org.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+
+	if _, noColor := os.LookupEnv("NO_COLOR"); noColor {
+		return false
+	}
+
+	return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+}
@@ -0,0 +1,198 @@
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/shihanng/tfvar/pkg/tfvar"
+	"github.com/spf13/cobra"
+)
+
+const (
+	defaultOutputPattern = "{{dir}}/{{format}}.tfvars"
+
+	// Placeholders of the --output pattern.
+	placeholderDir    = "{{dir}}"
+	placeholderBase   = "{{base}}"
+	placeholderFormat = "{{format}}"
+)
+
+// outputOptions describes where the generated output is written to instead of stdout.
+type outputOptions struct {
+	// pattern is the path of the output file, which may contain placeholders.
+	// The output is written to stdout when pattern is empty.
+	pattern string
+	force   bool
+}
+
+func (r *runner) outputOptions(cmd *cobra.Command) (outputOptions, error) {
+	output, err := cmd.Flags().GetString(flagOutput)
+	if err != nil {
+		return outputOptions{}, errors.Wrap(err, "cmd: get flag --output")
+	}
+
+	outputDir, err := cmd.Flags().GetString(flagOutputDir)
+	if err != nil {
+		return outputOptions{}, errors.Wrap(err, "cmd: get flag --output-dir")
+	}
+
+	force, err := cmd.Flags().GetBool(flagForce)
+	if err != nil {
+		return outputOptions{}, errors.Wrap(err, "cmd: get flag --force")
+	}
+
+	if outputDir != "" {
+		if output == "" {
+			output = defaultOutputPattern
+		}
+
+		output = filepath.Join(outputDir, output)
+	}
+
+	return outputOptions{
+		pattern: output,
+		force:   force,
+	}, nil
+}
+
+// isPerModule reports whether the pattern gives a different file for each module.
+func (o outputOptions) isPerModule() bool {
+	return strings.Contains(o.pattern, placeholderDir) || strings.Contains(o.pattern, placeholderBase)
+}
+
+// path returns the output file of the module in dir written in format.
+func (o outputOptions) path(dir, format string) string {
+	dir = filepath.Clean(dir)
+
+	return filepath.Clean(strings.NewReplacer(
+		placeholderDir, filepath.ToSlash(dir),
+		placeholderBase, filepath.Base(dir),
+		placeholderFormat, format,
+	).Replace(o.pattern))
+}
+
+// writeOutput writes the vars of the module in dir into the file given by the --output pattern.
+// Files that contain sensitive variables are only readable by the current user.
+func (r *runner) writeOutput(o outputOptions, dir, format string, writer tfvar.Writer, vars []tfvar.Variable) error {
+	filename := o.path(dir, format)
+
+	var buf bytes.Buffer
+
+	if err := writer.Write(&buf, vars); err != nil {
+		return err
+	}
+
+	perm := os.FileMode(0644)
+
+	for _, v := range vars {
+		if v.Sensitive {
+			perm = 0600
+			break
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return errors.Wrapf(err, "cmd: creating directory '%s'", filepath.Dir(filename))
+	}
+
+	r.log.Debugf("Writing %s", filename)
+
+	if o.force {
+		return writeFileAtomic(filename, buf.Bytes(), perm)
+	}
+
+	return writeFileExclusive(filename, buf.Bytes(), perm)
+}
+
+// checkOutputPaths returns an error if the --output pattern gives the same file for more than one of dirs,
+// which would silently overwrite each other.
+func (o outputOptions) checkOutputPaths(dirs []string, format string) error {
+	seen := make(map[string]string, len(dirs))
+
+	for _, dir := range dirs {
+		filename := o.path(dir, format)
+
+		if other, found := seen[filename]; found {
+			return errors.Errorf("cmd: --output gives the same file '%s' for %s and %s", filename, other, dir)
+		}
+
+		seen[filename] = dir
+	}
+
+	return nil
+}
+
+// writeFileAtomic writes data to a temporary file next to filename and renames it to filename,
+// so that readers never see a partially written file.
+func writeFileAtomic(filename string, data []byte, perm os.FileMode) error {
+	tmp, err := writeTempFile(filename, data, perm)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, filename); err != nil {
+		_ = os.Remove(tmp)
+		return errors.Wrapf(err, "cmd: writing file '%s'", filename)
+	}
+
+	return nil
+}
+
+// writeFileExclusive is like writeFileAtomic but fails if filename already exists. The temporary file
+// is linked to filename, which fails atomically if filename exists, unlike checking before renaming.
+func writeFileExclusive(filename string, data []byte, perm os.FileMode) error {
+	tmp, err := writeTempFile(filename, data, perm)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	if err := os.Link(tmp, filename); err != nil {
+		if os.IsExist(err) {
+			return errors.Errorf("cmd: '%s' already exists, use --force to overwrite", filename)
+		}
+
+		return errors.Wrapf(err, "cmd: writing file '%s'", filename)
+	}
+
+	return nil
+}
+
+// writeTempFile writes data to a new temporary file next to filename and returns its name.
+func writeTempFile(filename string, data []byte, perm os.FileMode) (name string, err error) {
+	f, err := ioutil.TempFile(filepath.Dir(filename), "."+filepath.Base(filename)+".tmp*")
+	if err != nil {
+		return "", errors.Wrapf(err, "cmd: creating temporary file for '%s'", filename)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = os.Remove(f.Name())
+		}
+	}()
+
+	if err := f.Chmod(perm); err != nil {
+		f.Close()
+		return "", errors.Wrapf(err, "cmd: setting permissions of '%s'", f.Name())
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return "", errors.Wrapf(err, "cmd: writing file '%s'", f.Name())
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return "", errors.Wrapf(err, "cmd: writing file '%s'", f.Name())
+	}
+
+	if err := f.Close(); err != nil {
+		return "", errors.Wrapf(err, "cmd: closing file '%s'", f.Name())
+	}
+
+	return f.Name(), nil
+}
@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPrecedence encodes the precedence rules of Terraform's variable definitions,
+// https://developer.hashicorp.com/terraform/language/values/variables#variable-definition-precedence
+// Later sources take precedence over earlier ones:
+//    1. Environment variables
+//    2. The terraform.tfvars file
+//    3. The terraform.tfvars.json file
+//    4. *.auto.tfvars or *.auto.tfvars.json files, in lexical order of their filenames
+//    5. -var and -var-file options on the command line, in the order they are provided
+//       (TF_CLI_ARGS_<command>, TF_CLI_ARGS, then the actual command line).
+// Each variable vN of testdata/precedence is defined in the first N sources, so its value
+// tells which source wins; v6 is also set by the command line options of each case.
+func TestPrecedence(t *testing.T) {
+	for i := 1; i <= 6; i++ {
+		unset := setenv(t, fmt.Sprintf("TF_VAR_v%d", i), "env")
+		defer unset()
+	}
+
+	const autoAssigned = `v1 = "env"
+v2 = "terraform.tfvars"
+v3 = "terraform.tfvars.json"
+v4 = "a.auto.tfvars"
+v5 = "b.auto.tfvars.json"
+`
+
+	tests := []struct {
+		name    string
+		args    string
+		environ map[string]string
+		want    string
+	}{
+		{
+			name: "auto-assigned sources only",
+			args: "",
+			want: "b.auto.tfvars.json",
+		},
+		{
+			name: "var over auto-assigned sources",
+			args: "--var v6=var",
+			want: "var",
+		},
+		{
+			name: "var-file over auto-assigned sources",
+			args: "--var-file testdata/precedence/one.tfvars",
+			want: "one.tfvars",
+		},
+		{
+			name: "var after var-file",
+			args: "--var-file testdata/precedence/one.tfvars --var v6=var",
+			want: "var",
+		},
+		{
+			name: "var-file after var",
+			args: "--var v6=var --var-file testdata/precedence/one.tfvars",
+			want: "one.tfvars",
+		},
+		{
+			name: "later var-file",
+			args: "--var-file testdata/precedence/one.tfvars --var-file testdata/precedence/two.tfvars",
+			want: "two.tfvars",
+		},
+		{
+			name: "later var-file reversed",
+			args: "--var-file testdata/precedence/two.tfvars --var-file testdata/precedence/one.tfvars",
+			want: "one.tfvars",
+		},
+		{
+			name: "later var",
+			args: "--var v6=first --var-file testdata/precedence/one.tfvars --var v6=last",
+			want: "last",
+		},
+		{
+			name: "TF_CLI_ARGS over TF_CLI_ARGS_plan",
+			args: "--tf-cli-args",
+			environ: map[string]string{
+				"TF_CLI_ARGS_plan": "-var v6=plan",
+				"TF_CLI_ARGS":      "-var-file=two.tfvars",
+			},
+			want: "two.tfvars",
+		},
+		{
+			name: "command line over TF_CLI_ARGS",
+			args: "--tf-cli-args --var-file testdata/precedence/one.tfvars",
+			environ: map[string]string{
+				"TF_CLI_ARGS_plan": "-var v6=plan",
+				"TF_CLI_ARGS":      "-var-file=two.tfvars",
+			},
+			want: "one.tfvars",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.environ {
+				unset := setenv(t, k, v)
+				defer unset()
+			}
+
+			os.Args = append(strings.Fields("tfvar testdata/precedence -a"), strings.Fields(tt.args)...)
+
+			var actual bytes.Buffer
+			cmd, sync := New(&actual, "dev")
+			defer sync()
+
+			require.NoError(t, cmd.Execute())
+			assert.Equal(t, autoAssigned+fmt.Sprintf("v6 = %q\n", tt.want), actual.String())
+		})
+	}
+}
@@ -0,0 +1,8 @@
+variable "region" {
+  default = "ap-northeast-1"
+}
+
+variable "db_password" {
+  type      = string
+  ephemeral = true
+}
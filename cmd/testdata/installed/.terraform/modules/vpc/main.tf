@@ -0,0 +1,8 @@
+variable "cidr" {
+  type    = string
+  default = "10.0.0.0/16"
+}
+
+variable "name" {
+  type = string
+}
@@ -0,0 +1,12 @@
+variable "image_id" {
+  type = string
+}
+
+variable "replicas" {
+  default = 2
+}
+
+variable "tag" {
+  type    = string
+  default = null
+}
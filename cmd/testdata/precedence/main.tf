@@ -0,0 +1,23 @@
+variable "v1" {
+  type = string
+}
+
+variable "v2" {
+  type = string
+}
+
+variable "v3" {
+  type = string
+}
+
+variable "v4" {
+  type = string
+}
+
+variable "v5" {
+  type = string
+}
+
+variable "v6" {
+  type = string
+}
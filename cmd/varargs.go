@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/shihanng/tfvar/pkg/tfvar"
+)
+
+// varArgsValue is a pflag.Value for --var and --var-file. Both flags append to the same list
+// so that the options are kept in the order they appear on the command line, which is the
+// order Terraform applies them in.
+type varArgsValue struct {
+	kind tfvar.VarArgKind
+	args *[]tfvar.VarArg
+}
+
+func newVarArgsValue(kind tfvar.VarArgKind, args *[]tfvar.VarArg) *varArgsValue {
+	return &varArgsValue{
+		kind: kind,
+		args: args,
+	}
+}
+
+func (v *varArgsValue) Set(val string) error {
+	*v.args = append(*v.args, tfvar.VarArg{Kind: v.kind, Value: val})
+	return nil
+}
+
+func (v *varArgsValue) Type() string {
+	return "stringArray"
+}
+
+func (v *varArgsValue) String() string {
+	var values []string
+
+	for _, a := range *v.args {
+		if a.Kind == v.kind {
+			values = append(values, a.Value)
+		}
+	}
+
+	if len(values) == 0 {
+		return ""
+	}
+
+	return "[" + strings.Join(values, ",") + "]"
+}
@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/fsnotify/fsnotify"
+	"github.com/shihanng/tfvar/pkg/configs"
+	"github.com/shihanng/tfvar/pkg/tfvar"
+	"github.com/spf13/cobra"
+)
+
+const flagDebounce = "debounce"
+
+func newWatchCmd(r *runner) *cobra.Command {
+	watchCmd := &cobra.Command{
+		Use:   "watch DIR",
+		Short: "Regenerate the output whenever the Terraform configurations in DIR change",
+		Long: `Watch the .tf, .tf.json, override, and variable definitions files
+(terraform.tfvars[.json] *.auto.tfvars[.json]) in DIR and regenerate the
+output whenever they change. Errors are printed without exiting.
+Stop watching with Ctrl-C.
+`,
+		RunE: r.reportErrors(r.watchRunE),
+		Args: cobra.ExactArgs(1),
+	}
+
+	watchCmd.Flags().Duration(flagDebounce, 300*time.Millisecond, "Wait for changes to settle for the given duration before regenerating")
+
+	return watchCmd
+}
+
+func (r *runner) watchRunE(cmd *cobra.Command, args []string) error {
+	dir := args[0]
+
+	if tfvar.IsArchive(dir) {
+		return errors.New("cmd: archives cannot be watched")
+	}
+
+	debounce, err := cmd.Flags().GetDuration(flagDebounce)
+	if err != nil {
+		return errors.Wrap(err, "cmd: get flag --debounce")
+	}
+
+	writer, format, err := r.writer(cmd)
+	if err != nil {
+		return err
+	}
+
+	output, err := r.outputOptions(cmd)
+	if err != nil {
+		return err
+	}
+
+	// Regenerating must be able to replace the file written previously.
+	output.force = true
+
+	// Changes of the output file itself, e.g. --output terraform.tfvars, must not trigger
+	// another regeneration, or it would never stop.
+	var outputFile string
+
+	if output.pattern != "" {
+		outputFile, err = filepath.Abs(output.path(dir, format))
+		if err != nil {
+			return errors.Wrap(err, "cmd: resolving --output")
+		}
+	}
+
+	opts, err := r.loadOptions(cmd)
+	if err != nil {
+		return err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return errors.Wrap(err, "cmd: creating file watcher")
+	}
+	defer watcher.Close()
+
+	if err := watcher.Add(dir); err != nil {
+		return errors.Wrapf(err, "cmd: watching '%s'", dir)
+	}
+
+	regenerate := func() {
+		vars, warnings, err := r.load(cmd.Context(), dir, opts)
+		if err == nil {
+			if output.pattern != "" {
+				err = r.writeOutput(output, dir, format, writer, vars)
+			} else {
+				err = writer.Write(r.out, vars)
+			}
+		}
+
+		if r.jsonDiags {
+			if err != nil || len(warnings) > 0 {
+				if err := tfvar.WriteDiagnosticsJSON(cmd.ErrOrStderr(), err, warnings...); err != nil {
+					r.log.Errorf("%v", err)
+				}
+			}
+
+			return
+		}
+
+		if err == nil {
+			return
+		}
+
+		fmt.Fprintf(cmd.OutOrStderr(), "Error: %v\n", r.diagnose(err))
+	}
+
+	regenerate()
+
+	var settled <-chan time.Time
+
+	for {
+		select {
+		case <-cmd.Context().Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+
+			if !isWatchedFile(filepath.Base(event.Name)) || isFile(event.Name, outputFile) {
+				continue
+			}
+
+			r.log.Debugf("%s %s", event.Op, event.Name)
+			settled = time.After(debounce)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+
+			r.log.Warnf("Watching %s: %v", dir, err)
+		case <-settled:
+			settled = nil
+
+			r.log.Infof("Regenerating %s", dir)
+			regenerate()
+		}
+	}
+}
+
+// isFile reports whether name refers to the file at the absolute path abs.
+func isFile(name, abs string) bool {
+	if abs == "" {
+		return false
+	}
+
+	p, err := filepath.Abs(name)
+
+	return err == nil && p == abs
+}
+
+// isWatchedFile reports whether a change of the file name may change the output.
+func isWatchedFile(name string) bool {
+	return configs.IsConfigFile(name) || tfvar.IsTFVarsFile(name)
+}
@@ -3,14 +3,20 @@ module github.com/shihanng/tfvar
 go 1.18
 
 require (
+	filippo.io/age v1.0.0
 	github.com/cockroachdb/errors v1.7.3
+	github.com/fsnotify/fsnotify v1.6.0
 	github.com/hashicorp/hcl/v2 v2.16.2
+	github.com/mattn/go-isatty v0.0.14
+	github.com/mattn/go-shellwords v1.0.12
+	github.com/pmezard/go-difflib v1.0.0
 	github.com/sebdah/goldie/v2 v2.5.3
-	github.com/spf13/afero v1.5.1
+	github.com/spf13/afero v1.6.0
 	github.com/spf13/cobra v1.0.0
 	github.com/stretchr/testify v1.6.1
 	github.com/zclconf/go-cty v1.13.1
 	go.uber.org/zap v1.16.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -28,14 +34,14 @@ require (
 	github.com/kylelemons/godebug v1.1.0 // indirect
 	github.com/mitchellh/go-wordwrap v1.0.1 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
-	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/sergi/go-diff v1.2.0 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
 	go.uber.org/atomic v1.7.0 // indirect
 	go.uber.org/multierr v1.6.0 // indirect
+	golang.org/x/crypto v0.0.0-20220525230936-793ad666bf5e // indirect
 	golang.org/x/lint v0.0.0-20201208152925-83fdc39ff7b5 // indirect
+	golang.org/x/sys v0.5.0 // indirect
 	golang.org/x/text v0.9.0 // indirect
 	gopkg.in/yaml.v2 v2.4.0 // indirect
-	gopkg.in/yaml.v3 v3.0.0-20200615113413-eeeca48fe776 // indirect
 	honnef.co/go/tools v0.0.1-2020.1.5 // indirect
 )
@@ -1,7 +1,10 @@
 package main
 
 import (
+	"context"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/shihanng/tfvar/cmd"
 )
@@ -9,10 +12,16 @@ import (
 var version = "dev"
 
 func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+
 	c, sync := cmd.New(os.Stdout, version)
-	defer sync()
 
-	if err := c.Execute(); err != nil {
+	err := c.ExecuteContext(ctx)
+
+	stop()
+	sync()
+
+	if err != nil {
 		os.Exit(1)
 	}
 }
@@ -11,6 +11,8 @@ import (
 func (v *Variable) merge(ov *Variable) hcl.Diagnostics {
 	var diags hcl.Diagnostics
 
+	v.History = append(v.History, ov.History...)
+
 	if ov.DescriptionSet {
 		v.Description = ov.Description
 		v.DescriptionSet = ov.DescriptionSet
@@ -33,6 +35,14 @@ func (v *Variable) merge(ov *Variable) hcl.Diagnostics {
 		v.Nullable = ov.Nullable
 		v.NullableSet = ov.NullableSet
 	}
+	if ov.EphemeralSet {
+		v.Ephemeral = ov.Ephemeral
+		v.EphemeralSet = ov.EphemeralSet
+	}
+	if ov.DeprecatedSet {
+		v.Deprecated = ov.Deprecated
+		v.DeprecatedSet = ov.DeprecatedSet
+	}
 
 	// If the override file overrode type without default or vice-versa then
 	// it may have created an invalid situation, which we'll catch now by
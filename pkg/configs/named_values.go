@@ -2,6 +2,7 @@ package configs
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/ext/typeexpr"
@@ -29,9 +30,18 @@ type Variable struct {
 
 	ParsingMode VariableParsingMode
 	Sensitive   bool
+	// Ephemeral indicates that the value of this variable is not persisted
+	// in the plan or state, as added in Terraform v1.10.
+	Ephemeral bool
+	// Deprecated is the message OpenTofu shows when a value is assigned to
+	// this variable, as added in OpenTofu v1.10. It is empty if the variable
+	// is not deprecated.
+	Deprecated string
 
 	DescriptionSet bool
 	SensitiveSet   bool
+	EphemeralSet   bool
+	DeprecatedSet  bool
 
 	// Nullable indicates that null is a valid value for this variable. Setting
 	// Nullable to false means that the module can expect this variable to
@@ -39,9 +49,35 @@ type Variable struct {
 	Nullable    bool
 	NullableSet bool
 
+	// History records where the attributes of this variable were set, in
+	// the order they were merged: the attributes of the base declaration
+	// followed by the ones of each override file.
+	History []VariableAttribute
+
 	DeclRange hcl.Range
 }
 
+// VariableAttribute records an attribute, e.g. default, set by a variable
+// block.
+type VariableAttribute struct {
+	Name string
+	// Range is the source range of the whole attribute, which includes the
+	// file that set it.
+	Range hcl.Range
+	// Override is true if the attribute was set by an override file.
+	Override bool
+}
+
+// String returns the attribute as e.g. "default at override.tf:2,3-20".
+func (a VariableAttribute) String() string {
+	s := fmt.Sprintf("%s at %s", a.Name, a.Range)
+	if a.Override {
+		s += " (override)"
+	}
+
+	return s
+}
+
 func decodeVariableBlock(block *hcl.Block, override bool) (*Variable, hcl.Diagnostics) {
 	v := &Variable{
 		Name:      block.Labels[0],
@@ -60,6 +96,8 @@ func decodeVariableBlock(block *hcl.Block, override bool) (*Variable, hcl.Diagno
 
 	content, diags := block.Body.Content(variableBlockSchema)
 
+	v.History = variableHistory(content.Attributes, override)
+
 	if !hclsyntax.ValidIdentifier(v.Name) {
 		diags = append(diags, &hcl.Diagnostic{
 			Severity: hcl.DiagError,
@@ -114,6 +152,27 @@ func decodeVariableBlock(block *hcl.Block, override bool) (*Variable, hcl.Diagno
 		v.SensitiveSet = true
 	}
 
+	if attr, exists := content.Attributes["ephemeral"]; exists {
+		valDiags := gohcl.DecodeExpression(attr.Expr, nil, &v.Ephemeral)
+		diags = append(diags, valDiags...)
+		v.EphemeralSet = true
+	}
+
+	if attr, exists := content.Attributes["deprecated"]; exists {
+		valDiags := gohcl.DecodeExpression(attr.Expr, nil, &v.Deprecated)
+		diags = append(diags, valDiags...)
+		v.DeprecatedSet = true
+
+		if !valDiags.HasErrors() && v.Deprecated == "" {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid deprecated value",
+				Detail:   "The deprecated message must not be empty.",
+				Subject:  attr.Expr.Range().Ptr(),
+			})
+		}
+	}
+
 	if attr, exists := content.Attributes["nullable"]; exists {
 		valDiags := gohcl.DecodeExpression(attr.Expr, nil, &v.Nullable)
 		diags = append(diags, valDiags...)
@@ -182,6 +241,24 @@ func decodeVariableBlock(block *hcl.Block, override bool) (*Variable, hcl.Diagno
 	return v, diags
 }
 
+// variableHistory returns the attributes of a variable block in source order.
+func variableHistory(attrs hcl.Attributes, override bool) []VariableAttribute {
+	var history []VariableAttribute
+	for name, attr := range attrs {
+		history = append(history, VariableAttribute{
+			Name:     name,
+			Range:    attr.Range,
+			Override: override,
+		})
+	}
+
+	sort.Slice(history, func(i, j int) bool {
+		return history[i].Range.Start.Byte < history[j].Range.Start.Byte
+	})
+
+	return history
+}
+
 func decodeVariableType(expr hcl.Expression) (cty.Type, *typeexpr.Defaults, VariableParsingMode, hcl.Diagnostics) {
 	if exprIsNativeQuotedString(expr) {
 		// If a user provides the pre-0.12 form of variable type argument where
@@ -321,6 +398,13 @@ var variableBlockSchema = &hcl.BodySchema{
 		{
 			Name: "nullable",
 		},
+		{
+			Name: "ephemeral",
+		},
+		{
+			// OpenTofu only
+			Name: "deprecated",
+		},
 	},
 	Blocks: []hcl.BlockHeaderSchema{
 		{
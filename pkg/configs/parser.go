@@ -2,7 +2,10 @@
 // The reason we have this "fork" is because Terraform moved this dependency
 // into internal/ package.
 //
-// Current version is v1.2.8
+// Current version is v1.2.8, with the top-level block types of the configuration
+// files updated to Terraform v1.14 (check, removed, ephemeral, and action).
+// Unknown top-level block types are reported as warnings instead of errors
+// so that configurations using newer language features can still be loaded.
 package configs
 
 import (
@@ -20,8 +23,32 @@ import (
 // It retains a cache of all files that are loaded so that they can be used
 // to create source code snippets in diagnostics, etc.
 type Parser struct {
-	fs afero.Afero
-	p  *hclparse.Parser
+	fs   afero.Afero
+	p    *hclparse.Parser
+	tofu TofuMode
+}
+
+// TofuMode selects whether a Parser follows OpenTofu's rules to select the
+// configuration files of a module directory. OpenTofu also reads .tofu and
+// .tofu.json files, which take precedence over the .tf and .tf.json files
+// of the same name, e.g. main.tofu over main.tf.
+type TofuMode int
+
+const (
+	// TofuAuto follows OpenTofu's rules if the directory contains any
+	// .tofu or .tofu.json file, and Terraform's otherwise.
+	TofuAuto TofuMode = iota
+	// TofuOn always follows OpenTofu's rules.
+	TofuOn
+	// TofuOff always follows Terraform's rules, ignoring .tofu and
+	// .tofu.json files.
+	TofuOff
+)
+
+// SetTofuMode selects the rules used to select the configuration files of
+// a module directory. The default is TofuAuto.
+func (p *Parser) SetTofuMode(mode TofuMode) {
+	p.tofu = mode
 }
 
 // NewParser creates and returns a new Parser that reads files from the given
@@ -81,3 +108,11 @@ func (p *Parser) LoadHCLFile(path string) (hcl.Body, hcl.Diagnostics) {
 
 	return file.Body, diags
 }
+
+// Files returns a map of the cached HCL file objects for all files that
+// have been loaded through this parser, with source filenames (as requested
+// when each file was opened) as the keys. The result can be used to render
+// diagnostics with source code snippets, e.g. with hcl.NewDiagnosticTextWriter.
+func (p *Parser) Files() map[string]*hcl.File {
+	return p.p.Files()
+}
@@ -1,6 +1,8 @@
 package configs
 
 import (
+	"strings"
+
 	"github.com/hashicorp/hcl/v2"
 )
 
@@ -33,13 +35,15 @@ func (p *Parser) loadConfigFile(path string, override bool) (*File, hcl.Diagnost
 
 	body, diags := p.LoadHCLFile(path)
 	if body == nil {
+		markVariableDiagnostics(diags)
 		return nil, diags
 	}
 
 	file := &File{}
 
 	content, contentDiags := body.Content(configFileSchema)
-	diags = append(diags, contentDiags...)
+	diags = append(diags, tolerateUnknownBlocks(contentDiags)...)
+	markFileVariableDiagnostics(diags, path, p.p.Sources()[path], content.Blocks)
 
 	for _, block := range content.Blocks {
 		switch block.Type {
@@ -49,6 +53,7 @@ func (p *Parser) loadConfigFile(path string, override bool) (*File, hcl.Diagnost
 		case "provider":
 		case "variable":
 			cfg, cfgDiags := decodeVariableBlock(block, override)
+			markVariableDiagnostics(cfgDiags)
 			diags = append(diags, cfgDiags...)
 			if cfg != nil {
 				file.Variables = append(file.Variables, cfg)
@@ -59,8 +64,12 @@ func (p *Parser) loadConfigFile(path string, override bool) (*File, hcl.Diagnost
 		case "module":
 		case "resource":
 		case "data":
+		case "ephemeral":
+		case "action":
 		case "moved":
+		case "removed":
 		case "import":
+		case "check":
 		default:
 			// Should never happen because the above cases should be exhaustive
 			// for all block type names in our schema.
@@ -113,11 +122,59 @@ var configFileSchema = &hcl.BodySchema{
 			Type:       "data",
 			LabelNames: []string{"type", "name"},
 		},
+		{
+			Type:       "ephemeral",
+			LabelNames: []string{"type", "name"},
+		},
+		{
+			Type:       "action",
+			LabelNames: []string{"type", "name"},
+		},
 		{
 			Type: "moved",
 		},
+		{
+			Type: "removed",
+		},
 		{
 			Type: "import",
 		},
+		{
+			Type:       "check",
+			LabelNames: []string{"name"},
+		},
 	},
 }
+
+// unsupportedBlockSummaries are the summaries of the diagnostics that the HCL native and JSON
+// syntaxes produce for the top-level blocks that are not in configFileSchema.
+var unsupportedBlockSummaries = map[string]bool{
+	"Unsupported block type":          true,
+	"Extraneous JSON object property": true,
+}
+
+// tolerateUnknownBlocks downgrades the errors about unsupported top-level blocks in diags to warnings,
+// so that configurations using block types introduced after configFileSchema was last updated can still
+// be loaded. The variables are not affected by those blocks. Misspellings of known block types, which HCL
+// suggests a known block type for, e.g. variabel, are still errors so that no variable silently disappears.
+func tolerateUnknownBlocks(diags hcl.Diagnostics) hcl.Diagnostics {
+	for _, diag := range diags {
+		if diag.Severity != hcl.DiagError || !unsupportedBlockSummaries[diag.Summary] {
+			continue
+		}
+
+		if strings.Contains(diag.Detail, "Did you mean") {
+			// A misspelled variable block is an error even with tfvar.Options.Lenient.
+			if strings.Contains(diag.Detail, `Did you mean "variable"?`) {
+				diag.Extra = variableDiagnostic{}
+			}
+
+			continue
+		}
+
+		diag.Severity = hcl.DiagWarning
+		diag.Detail += " tfvar does not know this block type and ignores it."
+	}
+
+	return diags
+}
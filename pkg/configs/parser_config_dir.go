@@ -30,6 +30,7 @@ import (
 func (p *Parser) LoadConfigDir(path string) (*Module, hcl.Diagnostics) {
 	primaryPaths, overridePaths, diags := p.dirFiles(path)
 	if diags.HasErrors() {
+		markVariableDiagnostics(diags)
 		return nil, diags
 	}
 
@@ -39,6 +40,7 @@ func (p *Parser) LoadConfigDir(path string) (*Module, hcl.Diagnostics) {
 	diags = append(diags, fDiags...)
 
 	mod, modDiags := NewModule(primary, override)
+	markVariableDiagnostics(modDiags)
 	diags = append(diags, modDiags...)
 
 	return mod, diags
@@ -76,6 +78,22 @@ func (p *Parser) dirFiles(dir string) (primary, override []string, diags hcl.Dia
 		return
 	}
 
+	tofu := p.tofu == TofuOn
+	if p.tofu == TofuAuto {
+		for _, info := range infos {
+			if !info.IsDir() && isTofuExt(fileExt(info.Name())) && !IsIgnoredFile(info.Name()) {
+				tofu = true
+				break
+			}
+		}
+	}
+
+	// names are the file names in dir, used to find .tf files that have a .tofu alternative.
+	names := make(map[string]struct{}, len(infos))
+	for _, info := range infos {
+		names[info.Name()] = struct{}{}
+	}
+
 	for _, info := range infos {
 		if info.IsDir() {
 			// We only care about files
@@ -89,6 +107,19 @@ func (p *Parser) dirFiles(dir string) (primary, override []string, diags hcl.Dia
 		}
 
 		baseName := name[:len(name)-len(ext)] // strip extension
+
+		if isTofuExt(ext) != tofu {
+			// OpenTofu prefers a .tofu file over the .tf file of the same name,
+			// while Terraform does not know .tofu files at all.
+			if tofu {
+				if _, found := names[baseName+tofuAlternative[ext]]; found {
+					continue
+				}
+			} else {
+				continue
+			}
+		}
+
 		isOverride := baseName == "override" || strings.HasSuffix(baseName, "_override")
 
 		fullPath := filepath.Join(dir, name)
@@ -102,18 +133,40 @@ func (p *Parser) dirFiles(dir string) (primary, override []string, diags hcl.Dia
 	return
 }
 
-// fileExt returns the Terraform configuration extension of the given
+// fileExt returns the Terraform or OpenTofu configuration extension of the given
 // path, or a blank string if it is not a recognized extension.
 func fileExt(path string) string {
-	if strings.HasSuffix(path, ".tf") {
+	switch {
+	case strings.HasSuffix(path, ".tf"):
 		return ".tf"
-	} else if strings.HasSuffix(path, ".tf.json") {
+	case strings.HasSuffix(path, ".tf.json"):
 		return ".tf.json"
-	} else {
+	case strings.HasSuffix(path, ".tofu"):
+		return ".tofu"
+	case strings.HasSuffix(path, ".tofu.json"):
+		return ".tofu.json"
+	default:
 		return ""
 	}
 }
 
+// tofuAlternative maps the Terraform configuration extensions to their OpenTofu counterparts.
+var tofuAlternative = map[string]string{
+	".tf":      ".tofu",
+	".tf.json": ".tofu.json",
+}
+
+func isTofuExt(ext string) bool {
+	return ext == ".tofu" || ext == ".tofu.json"
+}
+
+// IsConfigFile returns true if the given filename (which must not have a
+// directory path ahead of it) is a configuration file, including override
+// and OpenTofu files, that LoadConfigDir may load.
+func IsConfigFile(name string) bool {
+	return fileExt(name) != "" && !IsIgnoredFile(name)
+}
+
 // IsIgnoredFile returns true if the given filename (which must not have a
 // directory path ahead of it) should be ignored as e.g. an editor swap file.
 func IsIgnoredFile(name string) bool {
@@ -121,3 +174,11 @@ func IsIgnoredFile(name string) bool {
 		strings.HasSuffix(name, "~") || // vim
 		strings.HasPrefix(name, "#") && strings.HasSuffix(name, "#") // emacs
 }
+
+// IsConfigDir determines whether the given path refers to a directory that
+// exists and contains at least one Terraform config file (with a .tf or
+// .tf.json extension, or .tofu or .tofu.json for OpenTofu.)
+func (p *Parser) IsConfigDir(path string) bool {
+	primaryPaths, overridePaths, _ := p.dirFiles(path)
+	return (len(primaryPaths) + len(overridePaths)) > 0
+}
@@ -0,0 +1,146 @@
+package configs
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// TestLoadConfigDirVersions loads configurations that use the top-level blocks and variable
+// attributes introduced by each Terraform language version.
+func TestLoadConfigDirVersions(t *testing.T) {
+	tests := []struct {
+		dir  string
+		want []string
+	}{
+		{dir: "v0.12", want: []string{"availability_zone_names", "image_id"}},
+		{dir: "v0.13", want: []string{"image_id"}},
+		{dir: "v0.14", want: []string{"password"}},
+		{dir: "v1.1", want: []string{"name"}},
+		{dir: "v1.2", want: []string{"instance_type"}},
+		{dir: "v1.3", want: []string{"bucket"}},
+		{dir: "v1.5", want: []string{"bucket_name"}},
+		{dir: "v1.7", want: []string{"bucket_names"}},
+		{dir: "v1.9", want: []string{"max_size", "min_size"}},
+		{dir: "v1.10", want: []string{"db_password", "secret_id"}},
+		{dir: "v1.14", want: []string{"function_name"}},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.dir, func(t *testing.T) {
+			mod, diags := NewParser(nil).LoadConfigDir("testdata/versions/" + tt.dir)
+			require.Empty(t, diags)
+			assert.Equal(t, tt.want, variableNames(mod))
+		})
+	}
+}
+
+func TestLoadConfigDirUnknownBlocks(t *testing.T) {
+	mod, diags := NewParser(nil).LoadConfigDir("testdata/versions/future")
+	assert.False(t, diags.HasErrors())
+	assert.Equal(t, []string{"region", "zone"}, variableNames(mod))
+
+	require.Len(t, diags, 2)
+
+	for _, diag := range diags {
+		assert.Equal(t, hcl.DiagWarning, diag.Severity)
+		assert.Contains(t, diag.Detail, "tfvar does not know this block type and ignores it.")
+	}
+}
+
+func TestLoadConfigDirMisspelledBlocks(t *testing.T) {
+	_, diags := NewParser(nil).LoadConfigDir("testdata/versions/typo")
+	require.Len(t, diags, 2)
+
+	for _, diag := range diags {
+		assert.Equal(t, hcl.DiagError, diag.Severity)
+		assert.Contains(t, diag.Detail, `Did you mean "variable"?`)
+		assert.True(t, IsVariableDiagnostic(diag))
+	}
+}
+
+func TestLoadConfigDirEphemeral(t *testing.T) {
+	mod, diags := NewParser(nil).LoadConfigDir("testdata/versions/v1.10")
+	require.Empty(t, diags)
+	assert.True(t, mod.Variables["db_password"].Ephemeral)
+	assert.False(t, mod.Variables["secret_id"].Ephemeral)
+
+	mod, diags = NewParser(nil).LoadConfigDir("testdata/ephemeral-override")
+	require.Empty(t, diags)
+	assert.True(t, mod.Variables["token"].Ephemeral)
+}
+
+func TestLoadConfigDirHistory(t *testing.T) {
+	mod, diags := NewParser(nil).LoadConfigDir("testdata/ephemeral-override")
+	require.Empty(t, diags)
+
+	history := mod.Variables["token"].History
+	require.Len(t, history, 2)
+	assert.Equal(t, "type at testdata/ephemeral-override/main.tf:2,3-16", history[0].String())
+	assert.Equal(t, "ephemeral at testdata/ephemeral-override/main_override.tf:2,3-19 (override)", history[1].String())
+}
+
+func TestLoadConfigDirTofu(t *testing.T) {
+	tests := []struct {
+		name        string
+		mode        TofuMode
+		want        []string
+		wantRegion  string
+		wantDefault bool
+	}{
+		{
+			name:        "auto",
+			mode:        TofuAuto,
+			want:        []string{"cidr_block", "region", "zone"},
+			wantRegion:  "eu-central-1",
+			wantDefault: true,
+		},
+		{
+			name:        "on",
+			mode:        TofuOn,
+			want:        []string{"cidr_block", "region", "zone"},
+			wantRegion:  "eu-central-1",
+			wantDefault: true,
+		},
+		{
+			name:       "off",
+			mode:       TofuOff,
+			want:       []string{"cidr_block", "region"},
+			wantRegion: "us-east-1",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			parser := NewParser(nil)
+			parser.SetTofuMode(tt.mode)
+
+			mod, diags := parser.LoadConfigDir("testdata/tofu")
+			require.Empty(t, diags)
+			assert.Equal(t, tt.want, variableNames(mod))
+			assert.Equal(t, tt.wantRegion, mod.Variables["region"].Default.AsString())
+			assert.Equal(t, tt.wantDefault, mod.Variables["cidr_block"].Default != cty.NilVal)
+		})
+	}
+
+	mod, diags := NewParser(nil).LoadConfigDir("testdata/tofu")
+	require.Empty(t, diags)
+	assert.Equal(t, "Use region instead.", mod.Variables["zone"].Deprecated)
+}
+
+func variableNames(mod *Module) []string {
+	names := make([]string, 0, len(mod.Variables))
+	for name := range mod.Variables {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
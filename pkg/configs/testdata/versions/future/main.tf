@@ -0,0 +1,7 @@
+variable "region" {
+  type = string
+}
+
+experimental_block "example" {
+  region = var.region
+}
@@ -0,0 +1,7 @@
+variable "region" {
+  type = string
+}
+
+variabel "zone" {
+  type = string
+}
@@ -0,0 +1,37 @@
+variable "image_id" {
+  type        = string
+  description = "The id of the machine image (AMI) to use for the server."
+}
+
+variable "availability_zone_names" {
+  type    = list(string)
+  default = ["us-west-1a"]
+}
+
+resource "aws_instance" "app" {
+  ami               = var.image_id
+  availability_zone = var.availability_zone_names[0]
+
+  dynamic "ebs_block_device" {
+    for_each = []
+    content {
+      device_name = ebs_block_device.value
+    }
+  }
+}
+
+output "instance_ip_addr" {
+  value = aws_instance.app.private_ip
+}
+
+locals {
+  name = "app"
+}
+
+data "aws_ami" "ubuntu" {
+  most_recent = true
+}
+
+provider "aws" {
+  region = "us-west-1"
+}
@@ -0,0 +1,28 @@
+terraform {
+  required_providers {
+    aws = {
+      source  = "hashicorp/aws"
+      version = ">= 3.0"
+    }
+  }
+}
+
+variable "image_id" {
+  type = string
+
+  validation {
+    condition     = length(var.image_id) > 4 && substr(var.image_id, 0, 4) == "ami-"
+    error_message = "The image_id value must be a valid AMI id, starting with \"ami-\"."
+  }
+}
+
+module "servers" {
+  source = "./servers"
+  count  = 2
+
+  depends_on = [aws_instance.app]
+}
+
+resource "aws_instance" "app" {
+  ami = var.image_id
+}
@@ -0,0 +1,16 @@
+variable "name" {
+  type     = string
+  nullable = false
+  default  = "app"
+}
+
+moved {
+  from = aws_instance.a
+  to   = aws_instance.b
+}
+
+resource "aws_instance" "b" {
+  tags = {
+    Name = var.name
+  }
+}
@@ -0,0 +1,17 @@
+variable "secret_id" {
+  type = string
+}
+
+ephemeral "aws_secretsmanager_secret_version" "db" {
+  secret_id = var.secret_id
+}
+
+provider "postgresql" {
+  password = ephemeral.aws_secretsmanager_secret_version.db.secret_string
+}
+
+variable "db_password" {
+  type      = string
+  sensitive = true
+  ephemeral = true
+}
@@ -0,0 +1,19 @@
+variable "function_name" {
+  type = string
+}
+
+action "aws_lambda_invoke" "notify" {
+  config {
+    function_name = var.function_name
+    payload       = jsonencode({ event = "deployed" })
+  }
+}
+
+resource "terraform_data" "deploy" {
+  lifecycle {
+    action_trigger {
+      events  = [after_create]
+      actions = [action.aws_lambda_invoke.notify]
+    }
+  }
+}
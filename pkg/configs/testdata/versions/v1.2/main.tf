@@ -0,0 +1,21 @@
+variable "instance_type" {
+  type = string
+}
+
+resource "aws_instance" "app" {
+  instance_type = var.instance_type
+
+  lifecycle {
+    replace_triggered_by = [terraform_data.trigger]
+
+    precondition {
+      condition     = var.instance_type != ""
+      error_message = "The instance type must not be empty."
+    }
+
+    postcondition {
+      condition     = self.public_dns != ""
+      error_message = "EC2 instance must be in a VPC that has public DNS hostnames enabled."
+    }
+  }
+}
@@ -0,0 +1,17 @@
+variable "bucket" {
+  type = object({
+    name    = string
+    enabled = optional(bool, true)
+    website = optional(object({
+      index_document = optional(string, "index.html")
+      error_document = optional(string, "error.html")
+    }), {})
+  })
+  default = {
+    name = "example"
+  }
+}
+
+resource "terraform_data" "bucket" {
+  input = var.bucket
+}
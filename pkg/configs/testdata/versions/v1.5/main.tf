@@ -0,0 +1,23 @@
+variable "bucket_name" {
+  type = string
+}
+
+import {
+  to = aws_s3_bucket.this
+  id = var.bucket_name
+}
+
+resource "aws_s3_bucket" "this" {
+  bucket = var.bucket_name
+}
+
+check "health_check" {
+  data "http" "terraform_io" {
+    url = "https://www.terraform.io"
+  }
+
+  assert {
+    condition     = data.http.terraform_io.status_code == 200
+    error_message = "${data.http.terraform_io.url} returned an unhealthy status code"
+  }
+}
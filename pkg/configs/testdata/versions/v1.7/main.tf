@@ -0,0 +1,23 @@
+variable "bucket_names" {
+  type    = set(string)
+  default = []
+}
+
+removed {
+  from = aws_instance.legacy
+
+  lifecycle {
+    destroy = false
+  }
+}
+
+import {
+  for_each = var.bucket_names
+  to       = aws_s3_bucket.this[each.key]
+  id       = each.key
+}
+
+resource "aws_s3_bucket" "this" {
+  for_each = var.bucket_names
+  bucket   = each.key
+}
@@ -0,0 +1,21 @@
+variable "min_size" {
+  type = number
+}
+
+variable "max_size" {
+  type = number
+
+  validation {
+    condition     = var.max_size >= var.min_size
+    error_message = "max_size must be greater than or equal to min_size."
+  }
+}
+
+removed {
+  from = module.legacy
+
+  provisioner "local-exec" {
+    when    = destroy
+    command = "echo 'Removing legacy module'"
+  }
+}
@@ -0,0 +1,112 @@
+package configs
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// variableDiagnostic is set as the Extra of diagnostics about variable declarations.
+type variableDiagnostic struct{}
+
+// IsVariableDiagnostic reports whether diag is about the variable declarations of a module,
+// i.e. the variables returned by LoadConfigDir along with it may be incomplete or wrong.
+// Other diagnostics, e.g. a syntax error in a resource block, do not affect the variables.
+func IsVariableDiagnostic(diag *hcl.Diagnostic) bool {
+	_, ok := hcl.DiagnosticExtra[variableDiagnostic](diag)
+	return ok
+}
+
+func markVariableDiagnostics(diags hcl.Diagnostics) {
+	for _, diag := range diags {
+		if diag.Extra == nil {
+			diag.Extra = variableDiagnostic{}
+		}
+	}
+}
+
+// markFileVariableDiagnostics marks the diagnostics of parsing the file at path that may affect
+// its variable blocks: the ones within a variable block, on the line of a variable block header
+// that could not be parsed, or errors followed by a variable block header that was not decoded,
+// e.g. swallowed by an unclosed block. Diagnostics without a source range and the ones of JSON files,
+// which cannot be parsed partially, are always marked.
+func markFileVariableDiagnostics(diags hcl.Diagnostics, path string, src []byte, blocks hcl.Blocks) {
+	var ranges []hcl.Range
+
+	// decoded are the lines of the variable block headers that were decoded.
+	decoded := make(map[int]bool)
+
+	for _, block := range blocks {
+		if block.Type != "variable" {
+			continue
+		}
+
+		decoded[block.TypeRange.Start.Line] = true
+
+		rng := block.DefRange
+		if body, ok := block.Body.(*hclsyntax.Body); ok {
+			rng = hcl.RangeBetween(block.TypeRange, body.SrcRange)
+		}
+
+		ranges = append(ranges, rng)
+	}
+
+	isJSON := strings.HasSuffix(path, ".json")
+	lines := bytes.Split(src, []byte("\n"))
+
+	// lastMissing is the last line of a variable block header that was not decoded.
+	var lastMissing int
+
+	for i, line := range lines {
+		if isVariableHeader(line) && !decoded[i+1] {
+			lastMissing = i + 1
+		}
+	}
+
+	for _, diag := range diags {
+		if diag.Extra != nil {
+			continue
+		}
+
+		subject := diag.Subject
+		if subject == nil || isJSON {
+			diag.Extra = variableDiagnostic{}
+			continue
+		}
+
+		for _, rng := range ranges {
+			if rng.Overlaps(*subject) || (diag.Context != nil && rng.Overlaps(*diag.Context)) {
+				diag.Extra = variableDiagnostic{}
+				break
+			}
+		}
+
+		if diag.Extra != nil {
+			continue
+		}
+
+		if diag.Severity == hcl.DiagError && lastMissing >= subject.Start.Line {
+			diag.Extra = variableDiagnostic{}
+			continue
+		}
+
+		for _, r := range []*hcl.Range{diag.Context, subject} {
+			if r == nil || r.Start.Line < 1 || r.Start.Line > len(lines) {
+				continue
+			}
+
+			if isVariableHeader(lines[r.Start.Line-1]) {
+				diag.Extra = variableDiagnostic{}
+				break
+			}
+		}
+	}
+}
+
+// isVariableHeader reports whether line starts a variable block.
+func isVariableHeader(line []byte) bool {
+	fields := strings.Fields(string(line))
+	return len(fields) > 0 && (fields[0] == "variable" || strings.HasPrefix(fields[0], `variable"`))
+}
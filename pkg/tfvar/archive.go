@@ -0,0 +1,178 @@
+package tfvar
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/spf13/afero"
+)
+
+// archiveSubdirSeparator separates the archive path and the subdirectory within
+// the archive, e.g. module.zip//modules/vpc, as in Terraform's module sources.
+const archiveSubdirSeparator = "//"
+
+var archiveExts = []string{".zip", ".tar.gz", ".tgz"}
+
+// IsArchive reports whether p refers to a module archive supported by OpenArchive.
+func IsArchive(p string) bool {
+	archive, _ := splitArchivePath(p)
+	return archive != ""
+}
+
+// OpenArchive reads the .zip, .tar.gz, or .tgz archive at p into memory and returns
+// a read-only filesystem of its content that can be used with LoadFS, LookupTFVarsFilesFS,
+// and CollectFromFileFS. Nothing is extracted to disk.
+//
+// A subdirectory within the archive can be selected by appending it after a double-slash,
+// e.g. module.zip//modules/vpc. The returned dir is the directory to load within the
+// returned filesystem.
+func OpenArchive(p string) (afero.Fs, string, error) {
+	archive, subdir := splitArchivePath(p)
+	if archive == "" {
+		return nil, "", errors.Errorf("tfvar: '%s' is not a supported archive", p)
+	}
+
+	src, err := afero.ReadFile(afero.NewOsFs(), archive)
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "tfvar: reading archive '%s'", archive)
+	}
+
+	var afs afero.Fs
+
+	dir := path.Clean("/" + subdir)
+
+	if strings.HasSuffix(archive, ".zip") {
+		r, err := zip.NewReader(bytes.NewReader(src), int64(len(src)))
+		if err != nil {
+			return nil, "", errors.Wrapf(err, "tfvar: reading zip archive '%s'", archive)
+		}
+
+		afs = FromFS(r)
+	} else {
+		afs, err = readTarGz(bytes.NewReader(src))
+		if err != nil {
+			return nil, "", errors.Wrapf(err, "tfvar: reading tar.gz archive '%s'", archive)
+		}
+	}
+
+	// Paths are unrooted in both kinds of archives, as io/fs paths must be, so that filenames
+	// in diagnostics and history do not depend on the archive type.
+	dir = strings.TrimPrefix(dir, "/")
+	if dir == "" {
+		dir = "."
+	}
+
+	if isDir, err := afero.IsDir(afs, dir); err != nil || !isDir {
+		return nil, "", errors.Errorf("tfvar: directory '%s' not found in archive '%s'", subdir, archive)
+	}
+
+	return afs, dir, nil
+}
+
+// FromFS returns a read-only afero.Fs of fsys, e.g. an embed.FS or *zip.Reader, that can be used
+// with LoadFS, LookupTFVarsFilesFS, and CollectFromFileFS. Paths within fsys are unrooted, e.g.
+//    tfvar.LoadFS(tfvar.FromFS(fsys), "modules/vpc")
+func FromFS(fsys fs.FS) afero.Fs {
+	return afero.NewReadOnlyFs(afero.FromIOFS{FS: fsys})
+}
+
+// splitArchivePath splits p into the archive path and the subdirectory within the archive.
+// The returned archive is empty when p does not refer to a supported archive.
+func splitArchivePath(p string) (archive, subdir string) {
+	archive = p
+
+	for i := 0; i < len(p); {
+		j := strings.Index(p[i:], archiveSubdirSeparator)
+		if j < 0 {
+			break
+		}
+
+		if hasArchiveExt(p[:i+j]) {
+			archive, subdir = p[:i+j], p[i+j+len(archiveSubdirSeparator):]
+			break
+		}
+
+		i += j + len(archiveSubdirSeparator)
+	}
+
+	if !hasArchiveExt(archive) {
+		return "", ""
+	}
+
+	return archive, subdir
+}
+
+func hasArchiveExt(p string) bool {
+	for _, ext := range archiveExts {
+		if strings.HasSuffix(p, ext) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// readTarGz copies the regular files of a gzip-compressed tar archive into an
+// in-memory filesystem whose paths are unrooted, like the ones of FromFS.
+func readTarGz(r io.Reader) (afero.Fs, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "tfvar: unexpected gzip header")
+	}
+	defer gz.Close()
+
+	afs := afero.NewMemMapFs()
+
+	if err := afs.MkdirAll("/", 0755); err != nil {
+		return nil, errors.Wrap(err, "tfvar: creating root directory")
+	}
+
+	tr := tar.NewReader(gz)
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, errors.Wrap(err, "tfvar: unexpected tar entry")
+		}
+
+		name := path.Clean("/" + hdr.Name)
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := afs.MkdirAll(name, 0755); err != nil {
+				return nil, errors.Wrapf(err, "tfvar: creating directory '%s'", name)
+			}
+		case tar.TypeReg:
+			if err := afs.MkdirAll(path.Dir(name), 0755); err != nil {
+				return nil, errors.Wrapf(err, "tfvar: creating directory '%s'", path.Dir(name))
+			}
+
+			f, err := afs.Create(name)
+			if err != nil {
+				return nil, errors.Wrapf(err, "tfvar: creating file '%s'", name)
+			}
+
+			if _, err := io.Copy(f, tr); err != nil {
+				f.Close()
+				return nil, errors.Wrapf(err, "tfvar: reading file '%s'", name)
+			}
+
+			if err := f.Close(); err != nil {
+				return nil, errors.Wrapf(err, "tfvar: closing file '%s'", name)
+			}
+		}
+	}
+
+	return afero.NewReadOnlyFs(afero.NewBasePathFs(afs, "/")), nil
+}
@@ -0,0 +1,140 @@
+package tfvar
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/shihanng/tfvar/pkg/configs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestSplitArchivePath(t *testing.T) {
+	tests := []struct {
+		path        string
+		wantArchive string
+		wantSubdir  string
+	}{
+		{path: "module.zip", wantArchive: "module.zip"},
+		{path: "dist//module.tar.gz//modules/vpc", wantArchive: "dist//module.tar.gz", wantSubdir: "modules/vpc"},
+		{path: "module.tgz//", wantArchive: "module.tgz"},
+		{path: "testdata/normal"},
+		{path: "module.zip.d//main"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			archive, subdir := splitArchivePath(tt.path)
+			assert.Equal(t, tt.wantArchive, archive)
+			assert.Equal(t, tt.wantSubdir, subdir)
+			assert.Equal(t, tt.wantArchive != "", IsArchive(tt.path))
+		})
+	}
+}
+
+func TestOpenArchive(t *testing.T) {
+	tests := []struct {
+		name      string
+		path      string
+		want      []Variable
+		wantFiles int
+		assertion assert.ErrorAssertionFunc
+	}{
+		{
+			name: "zip",
+			path: "testdata/archive/module.zip",
+			want: []Variable{
+				{
+					Name: "region", Value: cty.StringVal("ap-northeast-1"), Type: cty.DynamicPseudoType, parsingMode: configs.VariableParseLiteral,
+					History: history("default", "main.tf", hcl.Pos{Line: 2, Column: 3, Byte: 22}, hcl.Pos{Line: 2, Column: 29, Byte: 48}),
+				},
+			},
+			wantFiles: 1,
+			assertion: assert.NoError,
+		},
+		{
+			name: "zip subdir",
+			path: "testdata/archive/module.zip//modules/vpc",
+			want: []Variable{
+				{
+					Name: "cidr_block", Type: cty.String, Required: true, parsingMode: configs.VariableParseLiteral,
+					History: history("type", "modules/vpc/main.tf", hcl.Pos{Line: 2, Column: 3, Byte: 26}, hcl.Pos{Line: 2, Column: 16, Byte: 39}),
+				},
+			},
+			assertion: assert.NoError,
+		},
+		{
+			name: "tar.gz",
+			path: "testdata/archive/module.tar.gz",
+			want: []Variable{
+				{
+					Name: "region", Value: cty.StringVal("ap-northeast-1"), Type: cty.DynamicPseudoType, parsingMode: configs.VariableParseLiteral,
+					History: history("default", "main.tf", hcl.Pos{Line: 2, Column: 3, Byte: 22}, hcl.Pos{Line: 2, Column: 29, Byte: 48}),
+				},
+			},
+			wantFiles: 1,
+			assertion: assert.NoError,
+		},
+		{
+			name: "tar.gz subdir",
+			path: "testdata/archive/module.tar.gz//modules/vpc/",
+			want: []Variable{
+				{
+					Name: "cidr_block", Type: cty.String, Required: true, parsingMode: configs.VariableParseLiteral,
+					History: history("type", "modules/vpc/main.tf", hcl.Pos{Line: 2, Column: 3, Byte: 26}, hcl.Pos{Line: 2, Column: 16, Byte: 39}),
+				},
+			},
+			assertion: assert.NoError,
+		},
+		{
+			name:      "unknown subdir",
+			path:      "testdata/archive/module.zip//modules/unknown",
+			assertion: assert.Error,
+		},
+		{
+			name:      "not found",
+			path:      "testdata/archive/unknown.zip",
+			assertion: assert.Error,
+		},
+		{
+			name:      "not an archive",
+			path:      "testdata/normal",
+			assertion: assert.Error,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs, dir, err := OpenArchive(tt.path)
+			tt.assertion(t, err)
+			if err != nil {
+				return
+			}
+
+			got, err := LoadFS(fs, dir)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+
+			files := LookupTFVarsFilesFS(fs, dir)
+			assert.Len(t, files, tt.wantFiles)
+
+			for _, f := range files {
+				assert.NoError(t, CollectFromFileFS(fs, f, map[string]UnparsedVariableValue{}))
+			}
+		})
+	}
+}
+
+func TestFromFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"modules/vpc/main.tf": &fstest.MapFile{Data: []byte(`variable "cidr_block" {}`)},
+	}
+
+	got, err := LoadFS(FromFS(fsys), "modules/vpc")
+	require.NoError(t, err)
+	assert.Equal(t, []Variable{
+		{Name: "cidr_block", Type: cty.DynamicPseudoType, Required: true, parsingMode: configs.VariableParseLiteral},
+	}, got)
+}
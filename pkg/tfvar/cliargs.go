@@ -0,0 +1,163 @@
+package tfvar
+
+import (
+	"os"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/mattn/go-shellwords"
+	"github.com/spf13/afero"
+)
+
+// cliArgsEnv is the environment variable Terraform reads extra command line arguments from.
+// TF_CLI_ARGS applies to every command, TF_CLI_ARGS_<command> only to the given command.
+const cliArgsEnv = "TF_CLI_ARGS"
+
+// VarArgKind is the kind of a VarArg.
+type VarArgKind int
+
+const (
+	// VarArgVar is a -var option, e.g. -var 'image_id=ami-abc123'.
+	VarArgVar VarArgKind = iota
+	// VarArgVarFile is a -var-file option, e.g. -var-file testing.tfvars.
+	VarArgVarFile
+)
+
+// StdinVarFile is the -var-file value that reads the variable definitions from the standard input,
+// see Options.Stdin.
+const StdinVarFile = "-"
+
+// VarArg is a -var or -var-file command line option. Terraform applies them in the order they appear
+// on the command line, with later ones taking precedence over earlier ones.
+type VarArg struct {
+	Kind  VarArgKind
+	Value string
+}
+
+// CollectFromVarArgs extracts the variable definitions from args in order, using CollectFromString
+// for -var and CollectFromFileFS for -var-file options.
+// If a nil filesystem is passed then the system's "real" filesystem will be used.
+func CollectFromVarArgs(fs afero.Fs, args []VarArg, to map[string]UnparsedVariableValue) error {
+	return collectFromVarArgsEnviron(fs, args, os.Environ(), to)
+}
+
+// collectFromVarArgsEnviron is like CollectFromVarArgs but decrypts the var files with the age keys
+// of environ, see collectFromFileEnviron.
+func collectFromVarArgsEnviron(fs afero.Fs, args []VarArg, environ []string, to map[string]UnparsedVariableValue) error {
+	for _, arg := range args {
+		var err error
+
+		switch arg.Kind {
+		case VarArgVar:
+			err = CollectFromString(arg.Value, to)
+		case VarArgVarFile:
+			err = collectFromFileEnviron(fs, arg.Value, environ, to)
+		default:
+			err = errors.Errorf("tfvar: unknown kind of var arg %d", arg.Kind)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ParseTFCLIArgs extracts the -var and -var-file options that Terraform adds to the given command,
+// e.g. plan, from the TF_CLI_ARGS and TF_CLI_ARGS_<command> variables of environ, in the "key=value"
+// form of os.Environ. Other options are ignored.
+//
+// The returned options are in Terraform's order: TF_CLI_ARGS_<command> first, then TF_CLI_ARGS.
+// Options given on the actual command line come after both of them.
+func ParseTFCLIArgs(environ []string, command string) ([]VarArg, error) {
+	suffix := strings.NewReplacer("-", "_", " ", "_").Replace(command)
+
+	var args []VarArg
+
+	for _, name := range []string{cliArgsEnv + "_" + suffix, cliArgsEnv} {
+		value := lookupEnviron(environ, name)
+		if value == "" {
+			continue
+		}
+
+		words, err := shellwords.Parse(value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "tfvar: failed to parse %s", name)
+		}
+
+		parsed, err := parseVarArgs(words)
+		if err != nil {
+			return nil, errors.Wrapf(err, "tfvar: failed to parse %s", name)
+		}
+
+		args = append(args, parsed...)
+	}
+
+	return args, nil
+}
+
+// parseVarArgs picks the -var and -var-file options from words following the syntax of Go's flag
+// package used by Terraform, i.e. -var=value, -var value, --var=value, or --var value.
+func parseVarArgs(words []string) ([]VarArg, error) {
+	var args []VarArg
+
+	for i := 0; i < len(words); i++ {
+		word := words[i]
+		if !strings.HasPrefix(word, "-") {
+			continue
+		}
+
+		name := strings.TrimPrefix(strings.TrimPrefix(word, "-"), "-")
+
+		var value string
+
+		hasValue := false
+		if eq := strings.Index(name, "="); eq >= 0 {
+			name, value = name[:eq], name[eq+1:]
+			hasValue = true
+		}
+
+		var kind VarArgKind
+
+		switch name {
+		case "var":
+			kind = VarArgVar
+		case "var-file":
+			kind = VarArgVarFile
+		default:
+			continue
+		}
+
+		if !hasValue {
+			if i+1 >= len(words) {
+				return nil, errors.Errorf("tfvar: flag needs an argument: -%s", name)
+			}
+
+			i++
+			value = words[i]
+		}
+
+		args = append(args, VarArg{Kind: kind, Value: value})
+	}
+
+	return args, nil
+}
+
+func lookupEnviron(environ []string, key string) string {
+	value, _ := lookupEnvironOK(environ, key)
+	return value
+}
+
+// lookupEnvironOK is like os.LookupEnv for environ.
+func lookupEnvironOK(environ []string, key string) (string, bool) {
+	prefix := key + "="
+
+	for _, raw := range environ {
+		if strings.HasPrefix(raw, prefix) {
+			return raw[len(prefix):], true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,105 @@
+package tfvar
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestParseTFCLIArgs(t *testing.T) {
+	type args struct {
+		environ []string
+		command string
+	}
+
+	tests := []struct {
+		name      string
+		args      args
+		want      []VarArg
+		assertion assert.ErrorAssertionFunc
+	}{
+		{
+			name: "plan",
+			args: args{
+				environ: []string{
+					`TF_CLI_ARGS=-input=false -var "name=my instance" -lock-timeout 10s`,
+					`TF_CLI_ARGS_plan=-var-file=prod.tfvars --var=region=us-west-1 -out plan.out`,
+					`TF_CLI_ARGS_apply=-var region=ignored`,
+				},
+				command: "plan",
+			},
+			want: []VarArg{
+				{Kind: VarArgVarFile, Value: "prod.tfvars"},
+				{Kind: VarArgVar, Value: "region=us-west-1"},
+				{Kind: VarArgVar, Value: "name=my instance"},
+			},
+			assertion: assert.NoError,
+		},
+		{
+			name: "command with dash",
+			args: args{
+				environ: []string{`TF_CLI_ARGS_force_unlock=-var a=b`},
+				command: "force-unlock",
+			},
+			want:      []VarArg{{Kind: VarArgVar, Value: "a=b"}},
+			assertion: assert.NoError,
+		},
+		{
+			name: "nothing",
+			args: args{
+				environ: []string{`TF_VAR_a=b`},
+				command: "plan",
+			},
+			want:      nil,
+			assertion: assert.NoError,
+		},
+		{
+			name: "missing argument",
+			args: args{
+				environ: []string{`TF_CLI_ARGS=-var`},
+				command: "plan",
+			},
+			want:      nil,
+			assertion: assert.Error,
+		},
+		{
+			name: "bad quote",
+			args: args{
+				environ: []string{`TF_CLI_ARGS_plan=-var 'a=b`},
+				command: "plan",
+			},
+			want:      nil,
+			assertion: assert.Error,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTFCLIArgs(tt.args.environ, tt.args.command)
+			tt.assertion(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCollectFromVarArgs(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/a.tfvars", []byte(`region = "from-file"`), 0644))
+
+	actual := make(map[string]UnparsedVariableValue)
+	require.NoError(t, CollectFromVarArgs(fs, []VarArg{
+		{Kind: VarArgVar, Value: "region=from-var"},
+		{Kind: VarArgVarFile, Value: "/a.tfvars"},
+	}, actual))
+
+	val, err := actual["region"].ParseVariableValue('L')
+	require.NoError(t, err)
+	assert.Equal(t, cty.StringVal("from-file"), val)
+
+	assert.Error(t, CollectFromVarArgs(fs, []VarArg{{Kind: VarArgVar, Value: "region"}}, actual))
+	assert.Error(t, CollectFromVarArgs(fs, []VarArg{{Kind: VarArgVarFile, Value: "/unknown.tfvars"}}, actual))
+	assert.Error(t, CollectFromVarArgs(fs, []VarArg{{Kind: 99}}, actual))
+}
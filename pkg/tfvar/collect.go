@@ -1,6 +1,9 @@
 package tfvar
 
 import (
+	"bytes"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -11,6 +14,7 @@ import (
 	"github.com/hashicorp/hcl/v2/hclsyntax"
 	"github.com/hashicorp/hcl/v2/json"
 	"github.com/shihanng/tfvar/pkg/configs"
+	"github.com/spf13/afero"
 	"github.com/zclconf/go-cty/cty"
 )
 
@@ -21,19 +25,27 @@ const (
 
 // LookupTFVarsFiles search for terraform.tfvars, terraform.tfvars.json, *.auto.tfvars, and *.auto.tfvars.json in dir. The value of dir is include in the returned value.
 func LookupTFVarsFiles(dir string) []string {
+	return LookupTFVarsFilesFS(nil, dir)
+}
+
+// LookupTFVarsFilesFS is like LookupTFVarsFiles but searches in the given filesystem.
+// If a nil filesystem is passed then the system's "real" filesystem will be used.
+func LookupTFVarsFilesFS(fs afero.Fs, dir string) []string {
+	afs := newAfero(fs)
+
 	var files []string
 
 	d := filepath.Join(dir, defaultVarsFilename)
-	if _, err := os.Stat(d); err == nil {
+	if _, err := afs.Stat(d); err == nil {
 		files = append(files, d)
 	}
 
 	dj := filepath.Join(dir, defaultVarsFilenameJSON)
-	if _, err := os.Stat(dj); err == nil {
+	if _, err := afs.Stat(dj); err == nil {
 		files = append(files, dj)
 	}
 
-	if infos, err := ioutil.ReadDir(dir); err == nil {
+	if infos, err := afs.ReadDir(dir); err == nil {
 		// "infos" is already sorted by name, so we just need to filter it here.
 		for _, info := range infos {
 			name := info.Name()
@@ -48,6 +60,12 @@ func LookupTFVarsFiles(dir string) []string {
 	return files
 }
 
+// IsTFVarsFile reports whether the given filename (which must not have a directory path ahead of it)
+// is one of the files LookupTFVarsFiles looks up.
+func IsTFVarsFile(name string) bool {
+	return name == defaultVarsFilename || name == defaultVarsFilenameJSON || isAutoVarFile(name)
+}
+
 // isAutoVarFile determines if the file ends with .auto.tfvars or .auto.tfvars.json
 // https://github.com/hashicorp/terraform/blob/e9d0822b2a60f15653da0120607e74df1e116422/command/meta.go#L635-L638
 func isAutoVarFile(path string) bool {
@@ -62,8 +80,13 @@ type UnparsedVariableValue interface {
 
 // CollectFromEnvVars extracts the variable definitions from all environment variables that prefixed with TF_VAR_.
 func CollectFromEnvVars(to map[string]UnparsedVariableValue) {
-	env := os.Environ()
-	for _, raw := range env {
+	CollectFromEnviron(os.Environ(), to)
+}
+
+// CollectFromEnviron is like CollectFromEnvVars but uses the given environment,
+// in the "key=value" form of os.Environ, instead of the one of the current process.
+func CollectFromEnviron(environ []string, to map[string]UnparsedVariableValue) {
+	for _, raw := range environ {
 		if !strings.HasPrefix(raw, varEnvPrefix) {
 			continue
 		}
@@ -102,36 +125,101 @@ func CollectFromString(raw string, to map[string]UnparsedVariableValue) error {
 	return nil
 }
 
-// CollectFromFile extracts the variable definitions from the given file.
+// CollectFromFile extracts the variable definitions from the given file. JSON, YAML, and dotenv files
+// encrypted with SOPS are decrypted in memory with the age keys of SOPS_AGE_KEY_FILE, see IsSOPSFile.
+// Other files are read as JSON if their names end with .json, or HCL otherwise, as Terraform does.
 func CollectFromFile(filename string, to map[string]UnparsedVariableValue) error {
-	src, err := ioutil.ReadFile(filename)
+	return CollectFromFileFS(nil, filename, to)
+}
+
+// CollectFromFileFS is like CollectFromFile but reads the file from the given filesystem.
+// If a nil filesystem is passed then the system's "real" filesystem will be used.
+func CollectFromFileFS(fs afero.Fs, filename string, to map[string]UnparsedVariableValue) error {
+	return collectFromFileEnviron(fs, filename, os.Environ(), to)
+}
+
+// collectFromFileEnviron is like CollectFromFileFS but decrypts the SOPS files with the age keys of
+// environ, in the "key=value" form of os.Environ, instead of the ones of the current process.
+func collectFromFileEnviron(fs afero.Fs, filename string, environ []string, to map[string]UnparsedVariableValue) error {
+	src, err := newAfero(fs).ReadFile(filename)
 	if err != nil {
 		return errors.Errorf("tfvar: reading file '%s'", filename)
 	}
 
-	var f *hcl.File
-	if strings.HasSuffix(filename, ".json") {
-		var hclDiags hcl.Diagnostics
+	if IsSOPSFile(src, filename) {
+		return collectFromSOPS(src, filename, environ, to)
+	}
+
+	return collectFromSource(src, filename, strings.HasSuffix(filename, ".json"), to)
+}
+
+// Formats of the variable definitions read by CollectFromReader.
+const (
+	VarFileFormatAuto = "auto"
+	VarFileFormatHCL  = "hcl"
+	VarFileFormatJSON = "json"
+)
+
+// StdinFilename is the name of the variable definitions read from the standard input in diagnostics.
+const StdinFilename = "<stdin>"
+
+// CollectFromReader is like CollectFromFile but reads the variable definitions from r, e.g. the
+// standard input, in the given format: VarFileFormatHCL, VarFileFormatJSON, or VarFileFormatAuto
+// (or empty) which detects JSON by its leading '{'. The name is used in diagnostics, e.g. StdinFilename.
+func CollectFromReader(r io.Reader, name, format string, to map[string]UnparsedVariableValue) error {
+	src, err := ioutil.ReadAll(r)
+	if err != nil {
+		return errors.Wrapf(err, "tfvar: reading '%s'", name)
+	}
+
+	var isJSON bool
+
+	switch format {
+	case VarFileFormatAuto, "":
+		isJSON = bytes.HasPrefix(bytes.TrimSpace(src), []byte("{"))
+	case VarFileFormatHCL:
+	case VarFileFormatJSON:
+		isJSON = true
+	default:
+		return errors.Errorf("tfvar: unknown variable definitions format '%s', must be one of: %s, %s, %s",
+			format, VarFileFormatAuto, VarFileFormatHCL, VarFileFormatJSON)
+	}
+
+	return collectFromSource(src, name, isJSON, to)
+}
+
+// collectFromSource extracts the variable definitions from src, the content of filename.
+func collectFromSource(src []byte, filename string, isJSON bool, to map[string]UnparsedVariableValue) error {
+	var (
+		f        *hcl.File
+		hclDiags hcl.Diagnostics
+	)
+
+	if isJSON {
 		f, hclDiags = json.Parse(src, filename)
-		if hclDiags.HasErrors() {
-			return errors.Wrapf(hclDiags, "tfvar: failed to parse '%s'", filename)
-		}
 	} else {
-		var hclDiags hcl.Diagnostics
 		f, hclDiags = hclsyntax.ParseConfig(src, filename, hcl.Pos{Line: 1, Column: 1})
-		if hclDiags.HasErrors() {
-			return errors.Wrapf(hclDiags, "tfvar: failed to parse '%s'", filename)
-		}
+	}
+
+	if f == nil {
+		f = &hcl.File{Bytes: src}
+	}
+
+	files := map[string]*hcl.File{filename: f}
+
+	if hclDiags.HasErrors() {
+		return newDiagnosticsError(fmt.Sprintf("tfvar: failed to parse '%s'", filename), hclDiags, files)
 	}
 
 	attrs, hclDiags := f.Body.JustAttributes()
 	if hclDiags.HasErrors() {
-		return errors.Wrap(hclDiags, "tfvar: failed to get attributes")
+		return newDiagnosticsError("tfvar: failed to get attributes", hclDiags, files)
 	}
 
 	for name, attr := range attrs {
 		to[name] = unparsedVariableValueExpression{
-			expr: attr.Expr,
+			expr:  attr.Expr,
+			files: files,
 		}
 	}
 
@@ -146,7 +234,12 @@ type unparsedVariableValueString struct {
 func (v unparsedVariableValueString) ParseVariableValue(mode configs.VariableParsingMode) (cty.Value, error) {
 	val, hclDiags := mode.Parse(v.name, v.str)
 	if hclDiags.HasErrors() {
-		return cty.Value{}, errors.Wrap(hclDiags, "tfvar: failed to parse unparsedVariableValueString")
+		// The value is parsed as if it was in a file of this name, see configs.VariableParsingMode.
+		files := map[string]*hcl.File{
+			fmt.Sprintf("<value for var.%s>", v.name): {Bytes: []byte(v.str)},
+		}
+
+		return cty.Value{}, newDiagnosticsError("tfvar: failed to parse unparsedVariableValueString", hclDiags, files)
 	}
 
 	return val, nil
@@ -154,12 +247,17 @@ func (v unparsedVariableValueString) ParseVariableValue(mode configs.VariablePar
 
 type unparsedVariableValueExpression struct {
 	expr hcl.Expression
+	// files are the parsed variable definitions files expr is from.
+	files map[string]*hcl.File
+	// ctx holds the functions expr may call, see AllowFunctions.
+	ctx *hcl.EvalContext
 }
 
 func (v unparsedVariableValueExpression) ParseVariableValue(_ configs.VariableParsingMode) (cty.Value, error) {
-	val, hclDiags := v.expr.Value(nil) // nil because no function calls or variable references are allowed here
+	// ctx is nil unless function calls are allowed, variable references are never allowed here.
+	val, hclDiags := v.expr.Value(v.ctx)
 	if hclDiags.HasErrors() {
-		return cty.Value{}, errors.Wrap(hclDiags, "tfvar: failed to parse unparsedVariableValueExpression")
+		return cty.Value{}, newDiagnosticsError("tfvar: failed to parse unparsedVariableValueExpression", hclDiags, v.files)
 	}
 
 	return val, nil
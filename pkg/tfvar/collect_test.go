@@ -2,10 +2,12 @@ package tfvar
 
 import (
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/hashicorp/hcl/v2"
 	"github.com/shihanng/tfvar/pkg/configs"
+	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/zclconf/go-cty/cty"
@@ -43,6 +45,24 @@ func TestLookupTFVarsFiles(t *testing.T) {
 	}
 }
 
+func TestLookupTFVarsFilesFS(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	for _, name := range []string{
+		"/module/terraform.tfvars",
+		"/module/b.auto.tfvars.json",
+		"/module/a.auto.tfvars",
+		"/module/other.tfvars",
+	} {
+		require.NoError(t, afero.WriteFile(fs, name, nil, 0644))
+	}
+
+	assert.Equal(t, []string{
+		"/module/terraform.tfvars",
+		"/module/a.auto.tfvars",
+		"/module/b.auto.tfvars.json",
+	}, LookupTFVarsFilesFS(fs, "/module"))
+}
+
 func TestCollectFromEnvVars(t *testing.T) {
 	require.NoError(t, os.Setenv("MY_VAR", "my-value"))
 	require.NoError(t, os.Setenv("TF_VAR_availability_zone_names", `'["us-west-1a"]'`))
@@ -60,6 +80,24 @@ func TestCollectFromEnvVars(t *testing.T) {
 	assert.Equal(t, expected, actual)
 }
 
+func TestCollectFromEnviron(t *testing.T) {
+	actual := make(map[string]UnparsedVariableValue)
+	CollectFromEnviron([]string{
+		"MY_VAR=my-value",
+		"TF_VAR_region=ap-northeast-1",
+		"TF_VAR_broken",
+	}, actual)
+
+	expected := map[string]UnparsedVariableValue{
+		"region": unparsedVariableValueString{
+			str:  "ap-northeast-1",
+			name: "region",
+		},
+	}
+
+	assert.Equal(t, expected, actual)
+}
+
 func TestCollectFromString(t *testing.T) {
 	type args struct {
 		raw string
@@ -193,6 +231,99 @@ func TestCollectFromFile(t *testing.T) {
 	}
 }
 
+func TestCollectFromFileFS(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/my.tfvars", []byte(`region = "ap-northeast-1"`), 0644))
+
+	actual := make(map[string]UnparsedVariableValue)
+	require.NoError(t, CollectFromFileFS(fs, "/my.tfvars", actual))
+
+	val, err := actual["region"].ParseVariableValue(configs.VariableParseLiteral)
+	require.NoError(t, err)
+	assert.Equal(t, cty.StringVal("ap-northeast-1"), val)
+
+	assert.Error(t, CollectFromFileFS(fs, "testdata/normal.tfvars", actual))
+}
+
+func TestCollectFromFileNotSOPS(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	// YAML and dotenv files that are not encrypted are read as HCL, as before SOPS was supported.
+	for _, filename := range []string{"/my.yaml", "/my.yml", "/my.env"} {
+		require.NoError(t, afero.WriteFile(fs, filename, []byte(`region = "ap-northeast-1"`), 0644))
+
+		actual := make(map[string]UnparsedVariableValue)
+		require.NoError(t, CollectFromFileFS(fs, filename, actual), filename)
+
+		val, err := actual["region"].ParseVariableValue(configs.VariableParseLiteral)
+		require.NoError(t, err)
+		assert.Equal(t, cty.StringVal("ap-northeast-1"), val)
+	}
+}
+
+func TestCollectFromReader(t *testing.T) {
+	tests := []struct {
+		name      string
+		src       string
+		format    string
+		want      cty.Value
+		assertion assert.ErrorAssertionFunc
+	}{
+		{
+			name:      "auto hcl",
+			src:       `region = "ap-northeast-1"`,
+			want:      cty.StringVal("ap-northeast-1"),
+			assertion: assert.NoError,
+		},
+		{
+			name:      "auto json",
+			src:       ` {"region": "ap-northeast-1"}`,
+			format:    VarFileFormatAuto,
+			want:      cty.StringVal("ap-northeast-1"),
+			assertion: assert.NoError,
+		},
+		{
+			name:      "json",
+			src:       `{"region": "ap-northeast-1"}`,
+			format:    VarFileFormatJSON,
+			want:      cty.StringVal("ap-northeast-1"),
+			assertion: assert.NoError,
+		},
+		{
+			name:      "hcl as json",
+			src:       `region = "ap-northeast-1"`,
+			format:    VarFileFormatJSON,
+			assertion: assert.Error,
+		},
+		{
+			name:      "unknown format",
+			src:       `region = "ap-northeast-1"`,
+			format:    "yaml",
+			assertion: assert.Error,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			actual := make(map[string]UnparsedVariableValue)
+			err := CollectFromReader(strings.NewReader(tt.src), StdinFilename, tt.format, actual)
+			tt.assertion(t, err)
+
+			if err != nil {
+				return
+			}
+
+			val, err := actual["region"].ParseVariableValue(configs.VariableParseLiteral)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, val)
+		})
+	}
+
+	err := CollectFromReader(strings.NewReader(`region =`), StdinFilename, VarFileFormatHCL, map[string]UnparsedVariableValue{})
+	assert.Contains(t, err.Error(), "<stdin>:1,9")
+}
+
 func TestParseValues(t *testing.T) {
 	type args struct {
 		from map[string]UnparsedVariableValue
@@ -0,0 +1,230 @@
+package tfvar
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/cockroachdb/errors"
+	"github.com/hashicorp/hcl/v2"
+)
+
+// DiagnosticsError is returned when Terraform configurations or variable definitions files
+// cannot be parsed. It keeps the parsed files so that the diagnostics can be written with
+// the source code snippets they refer to, e.g.
+//    var diagErr *tfvar.DiagnosticsError
+//    if errors.As(err, &diagErr) {
+//    	_ = diagErr.WriteDiagnostics(os.Stderr, 0, false)
+//    }
+type DiagnosticsError struct {
+	// Summary describes what failed, e.g. "tfvar: loading config".
+	Summary     string
+	Diagnostics hcl.Diagnostics
+	// Files are the parsed files the diagnostics refer to, by filename.
+	Files map[string]*hcl.File
+}
+
+func newDiagnosticsError(summary string, diags hcl.Diagnostics, files map[string]*hcl.File) error {
+	return errors.WithStack(&DiagnosticsError{
+		Summary:     summary,
+		Diagnostics: diags,
+		Files:       files,
+	})
+}
+
+func (e *DiagnosticsError) Error() string {
+	return e.Summary + ": " + e.Diagnostics.Error()
+}
+
+func (e *DiagnosticsError) Unwrap() error {
+	return e.Diagnostics
+}
+
+// WriteDiagnostics writes each diagnostic to w with its file, line, highlighted source code
+// snippet, and detail, as Terraform does. Details are wrapped at width unless it is 0.
+// ANSI escape sequences are used to highlight the output if color is set.
+func (e *DiagnosticsError) WriteDiagnostics(w io.Writer, width uint, color bool) error {
+	return errors.Wrap(
+		hcl.NewDiagnosticTextWriter(w, e.Files, width, color).WriteDiagnostics(e.Diagnostics),
+		"tfvar: writing diagnostics",
+	)
+}
+
+// jsonDiagnosticsFormatVersion is the version of the JSON format of terraform validate -json that
+// WriteDiagnosticsJSON follows.
+const jsonDiagnosticsFormatVersion = "1.0"
+
+type jsonDiagnostics struct {
+	FormatVersion string           `json:"format_version,omitempty"`
+	Valid         bool             `json:"valid"`
+	ErrorCount    int              `json:"error_count"`
+	WarningCount  int              `json:"warning_count"`
+	Diagnostics   []jsonDiagnostic `json:"diagnostics"`
+}
+
+type jsonDiagnostic struct {
+	Severity string     `json:"severity"`
+	Summary  string     `json:"summary"`
+	Detail   string     `json:"detail"`
+	Range    *jsonRange `json:"range,omitempty"`
+}
+
+type jsonRange struct {
+	Filename string  `json:"filename"`
+	Start    jsonPos `json:"start"`
+	End      jsonPos `json:"end"`
+}
+
+type jsonPos struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+	Byte   int `json:"byte"`
+}
+
+// WriteDiagnosticsJSON writes err and warnings to w as JSON in the format of terraform validate -json, e.g.
+//    {
+//      "format_version": "1.0",
+//      "valid": false,
+//      "error_count": 1,
+//      "warning_count": 0,
+//      "diagnostics": [
+//        {
+//          "severity": "error",
+//          "summary": "Invalid block definition",
+//          "detail": "A block definition must have block content delimited by \"{\" and \"}\", ...",
+//          "range": {
+//            "filename": "main.tf",
+//            "start": {"line": 1, "column": 25, "byte": 24},
+//            "end": {"line": 2, "column": 1, "byte": 25}
+//          }
+//        }
+//      ]
+//    }
+// Each diagnostic of a DiagnosticsError is written with its source range. Any other error is
+// written as a single error diagnostic without range. A nil err is written as valid.
+// The warnings, e.g. Result.Warnings, are written after the errors, see Warning.HCLDiagnostic.
+func WriteDiagnosticsJSON(w io.Writer, err error, warnings ...Warning) error {
+	out := newJSONDiagnostics(err, warnings)
+	out.FormatVersion = jsonDiagnosticsFormatVersion
+
+	return writeJSON(w, out)
+}
+
+// ModuleDiagnostics are the outcome of generating the variables of the module in Dir.
+type ModuleDiagnostics struct {
+	Dir      string
+	Err      error
+	Warnings []Warning
+}
+
+type jsonModulesDiagnostics struct {
+	FormatVersion string                     `json:"format_version"`
+	Valid         bool                       `json:"valid"`
+	ErrorCount    int                        `json:"error_count"`
+	WarningCount  int                        `json:"warning_count"`
+	Modules       map[string]jsonDiagnostics `json:"modules"`
+}
+
+// WriteModulesDiagnosticsJSON is like WriteDiagnosticsJSON for several modules. The diagnostics
+// of each module are written in the format of WriteDiagnosticsJSON, keyed by its Dir, e.g.
+//    {
+//      "format_version": "1.0",
+//      "valid": false,
+//      "error_count": 1,
+//      "warning_count": 0,
+//      "modules": {
+//        "live/app": {"valid": true, "error_count": 0, "warning_count": 0, "diagnostics": []},
+//        "live/network": {"valid": false, "error_count": 1, "warning_count": 0, "diagnostics": [...]}
+//      }
+//    }
+func WriteModulesDiagnosticsJSON(w io.Writer, modules []ModuleDiagnostics) error {
+	out := jsonModulesDiagnostics{
+		FormatVersion: jsonDiagnosticsFormatVersion,
+		Valid:         true,
+		Modules:       make(map[string]jsonDiagnostics, len(modules)),
+	}
+
+	for _, m := range modules {
+		diags := newJSONDiagnostics(m.Err, m.Warnings)
+
+		out.Valid = out.Valid && diags.Valid
+		out.ErrorCount += diags.ErrorCount
+		out.WarningCount += diags.WarningCount
+		out.Modules[m.Dir] = diags
+	}
+
+	return writeJSON(w, out)
+}
+
+// HCLDiagnostic returns the warning as an HCL diagnostic of warning severity, based on w.Diagnostic
+// if any, e.g. an error in a configuration that is tolerated with Options.Lenient.
+func (w Warning) HCLDiagnostic() *hcl.Diagnostic {
+	if w.Diagnostic != nil {
+		d := *w.Diagnostic
+		d.Severity = hcl.DiagWarning
+
+		return &d
+	}
+
+	return &hcl.Diagnostic{
+		Severity: hcl.DiagWarning,
+		Summary:  w.String(),
+	}
+}
+
+func newJSONDiagnostics(err error, warnings []Warning) jsonDiagnostics {
+	out := jsonDiagnostics{
+		Valid:       true,
+		Diagnostics: []jsonDiagnostic{},
+	}
+
+	var diags hcl.Diagnostics
+
+	var diagErr *DiagnosticsError
+	if errors.As(err, &diagErr) {
+		diags = diagErr.Diagnostics
+	} else if err != nil {
+		diags = hcl.Diagnostics{{
+			Severity: hcl.DiagError,
+			Summary:  err.Error(),
+		}}
+	}
+
+	for _, w := range warnings {
+		diags = append(diags, w.HCLDiagnostic())
+	}
+
+	for _, d := range diags {
+		jd := jsonDiagnostic{
+			Severity: "error",
+			Summary:  d.Summary,
+			Detail:   d.Detail,
+		}
+
+		if d.Severity == hcl.DiagWarning {
+			jd.Severity = "warning"
+			out.WarningCount++
+		} else {
+			out.Valid = false
+			out.ErrorCount++
+		}
+
+		if d.Subject != nil {
+			jd.Range = &jsonRange{
+				Filename: d.Subject.Filename,
+				Start:    jsonPos{Line: d.Subject.Start.Line, Column: d.Subject.Start.Column, Byte: d.Subject.Start.Byte},
+				End:      jsonPos{Line: d.Subject.End.Line, Column: d.Subject.End.Column, Byte: d.Subject.End.Byte},
+			}
+		}
+
+		out.Diagnostics = append(out.Diagnostics, jd)
+	}
+
+	return out
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return errors.Wrap(enc.Encode(v), "tfvar: writing diagnostics")
+}
@@ -0,0 +1,155 @@
+package tfvar
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/shihanng/tfvar/pkg/configs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiagnosticsError(t *testing.T) {
+	tests := []struct {
+		name    string
+		collect func() error
+		want    string
+	}{
+		{
+			name: "config",
+			collect: func() error {
+				_, err := Load("testdata/bad")
+				return err
+			},
+			want: `Error: Invalid block definition
+
+  on testdata/bad/main.tf line 1:
+   1: variable "resource_name"
+`,
+		},
+		{
+			name: "var file",
+			collect: func() error {
+				return CollectFromFile("testdata/bad.tfvars", make(map[string]UnparsedVariableValue))
+			},
+			want: `Error: Invalid block definition
+
+  on testdata/bad.tfvars line 1:
+   1: prefix "<RESOURCE_PREFIX>"
+`,
+		},
+		{
+			name: "value",
+			collect: func() error {
+				vars := []Variable{{Name: "ports", parsingMode: configs.VariableParseHCL}}
+				from := make(map[string]UnparsedVariableValue)
+				require.NoError(t, CollectFromString("ports=[80,", from))
+				_, err := ParseValues(from, vars)
+				return err
+			},
+			want: `
+  on <value for var.ports> line 1:
+   1: [80,
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var diagErr *DiagnosticsError
+			require.True(t, errors.As(tt.collect(), &diagErr))
+
+			var buf bytes.Buffer
+			require.NoError(t, diagErr.WriteDiagnostics(&buf, 0, false))
+			assert.Contains(t, buf.String(), tt.want)
+		})
+	}
+}
+
+func TestWriteDiagnosticsJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		warnings []Warning
+		want     string
+	}{
+		{
+			name: "valid",
+			err:  nil,
+			want: `{"format_version": "1.0", "valid": true, "error_count": 0, "warning_count": 0, "diagnostics": []}`,
+		},
+		{
+			name: "other error",
+			err:  errors.New("tfvar: reading file 'x.tfvars'"),
+			want: `{"format_version": "1.0", "valid": false, "error_count": 1, "warning_count": 0, "diagnostics": [
+				{"severity": "error", "summary": "tfvar: reading file 'x.tfvars'", "detail": ""}
+			]}`,
+		},
+		{
+			name: "diagnostics",
+			err: newDiagnosticsError("tfvar: loading config", hcl.Diagnostics{
+				{
+					Severity: hcl.DiagWarning,
+					Summary:  "Deprecated",
+				},
+				{
+					Severity: hcl.DiagError,
+					Summary:  "Invalid",
+					Detail:   "Bad value.",
+					Subject: &hcl.Range{
+						Filename: "main.tf",
+						Start:    hcl.Pos{Line: 1, Column: 2, Byte: 1},
+						End:      hcl.Pos{Line: 1, Column: 5, Byte: 4},
+					},
+				},
+			}, nil),
+			want: `{"format_version": "1.0", "valid": false, "error_count": 1, "warning_count": 1, "diagnostics": [
+				{"severity": "warning", "summary": "Deprecated", "detail": ""},
+				{"severity": "error", "summary": "Invalid", "detail": "Bad value.", "range": {
+					"filename": "main.tf",
+					"start": {"line": 1, "column": 2, "byte": 1},
+					"end": {"line": 1, "column": 5, "byte": 4}
+				}}
+			]}`,
+		},
+		{
+			name: "warnings",
+			warnings: []Warning{
+				{Variable: "zone", Message: "value assigned to undeclared variable"},
+				{Message: "Invalid expression", Diagnostic: &hcl.Diagnostic{Severity: hcl.DiagError, Summary: "Invalid expression"}},
+			},
+			want: `{"format_version": "1.0", "valid": true, "error_count": 0, "warning_count": 2, "diagnostics": [
+				{"severity": "warning", "summary": "zone: value assigned to undeclared variable", "detail": ""},
+				{"severity": "warning", "summary": "Invalid expression", "detail": ""}
+			]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, WriteDiagnosticsJSON(&buf, tt.err, tt.warnings...))
+			assert.JSONEq(t, tt.want, buf.String())
+		})
+	}
+}
+
+func TestWriteModulesDiagnosticsJSON(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteModulesDiagnosticsJSON(&buf, []ModuleDiagnostics{
+		{Dir: "live/app", Warnings: []Warning{{Variable: "zone", Message: "value assigned to undeclared variable"}}},
+		{Dir: "live/network", Err: errors.New("tfvar: loading config")},
+	}))
+	assert.JSONEq(t, `{"format_version": "1.0", "valid": false, "error_count": 1, "warning_count": 1, "modules": {
+		"live/app": {"valid": true, "error_count": 0, "warning_count": 1, "diagnostics": [
+			{"severity": "warning", "summary": "zone: value assigned to undeclared variable", "detail": ""}
+		]},
+		"live/network": {"valid": false, "error_count": 1, "warning_count": 0, "diagnostics": [
+			{"severity": "error", "summary": "tfvar: loading config", "detail": ""}
+		]}
+	}}`, buf.String())
+}
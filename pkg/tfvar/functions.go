@@ -0,0 +1,537 @@
+package tfvar
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/cockroachdb/errors"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/ext/tryfunc"
+	"github.com/spf13/afero"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+	"github.com/zclconf/go-cty/cty/function"
+	"github.com/zclconf/go-cty/cty/function/stdlib"
+	"gopkg.in/yaml.v3"
+)
+
+// Functions returns the functions var files may call when they are allowed, see AllowFunctions.
+// They are the string, collection, encoding, and type conversion functions of Terraform, plus
+// file, which reads a file of fs relative to dir, and env, which looks up an environment variable
+// in environ, in the "key=value" form of os.Environ. Functions that reach the network or run
+// commands are not available. If a nil filesystem is passed then the system's "real" filesystem
+// will be used.
+func Functions(fs afero.Fs, dir string, environ []string) map[string]function.Function {
+	return map[string]function.Function{
+		// Strings.
+		"chomp":       stdlib.ChompFunc,
+		"endswith":    endsWithFunc,
+		"format":      stdlib.FormatFunc,
+		"formatlist":  stdlib.FormatListFunc,
+		"indent":      stdlib.IndentFunc,
+		"join":        stdlib.JoinFunc,
+		"lower":       stdlib.LowerFunc,
+		"regex":       stdlib.RegexFunc,
+		"regexall":    stdlib.RegexAllFunc,
+		"replace":     stdlib.ReplaceFunc,
+		"split":       stdlib.SplitFunc,
+		"startswith":  startsWithFunc,
+		"strcontains": strContainsFunc,
+		"strlen":      stdlib.StrlenFunc,
+		"strrev":      stdlib.ReverseFunc,
+		"substr":      stdlib.SubstrFunc,
+		"title":       stdlib.TitleFunc,
+		"trim":        stdlib.TrimFunc,
+		"trimprefix":  stdlib.TrimPrefixFunc,
+		"trimspace":   stdlib.TrimSpaceFunc,
+		"trimsuffix":  stdlib.TrimSuffixFunc,
+		"upper":       stdlib.UpperFunc,
+
+		// Collections.
+		"alltrue":         allTrueFunc,
+		"anytrue":         anyTrueFunc,
+		"chunklist":       stdlib.ChunklistFunc,
+		"coalesce":        stdlib.CoalesceFunc,
+		"coalescelist":    stdlib.CoalesceListFunc,
+		"compact":         stdlib.CompactFunc,
+		"concat":          stdlib.ConcatFunc,
+		"contains":        stdlib.ContainsFunc,
+		"distinct":        stdlib.DistinctFunc,
+		"element":         stdlib.ElementFunc,
+		"flatten":         stdlib.FlattenFunc,
+		"index":           stdlib.IndexFunc,
+		"keys":            stdlib.KeysFunc,
+		"length":          stdlib.LengthFunc,
+		"lookup":          stdlib.LookupFunc,
+		"matchkeys":       matchKeysFunc,
+		"merge":           stdlib.MergeFunc,
+		"one":             oneFunc,
+		"range":           stdlib.RangeFunc,
+		"reverse":         stdlib.ReverseListFunc,
+		"setintersection": stdlib.SetIntersectionFunc,
+		"setproduct":      stdlib.SetProductFunc,
+		"setsubtract":     stdlib.SetSubtractFunc,
+		"setunion":        stdlib.SetUnionFunc,
+		"slice":           stdlib.SliceFunc,
+		"sort":            stdlib.SortFunc,
+		"sum":             sumFunc,
+		"transpose":       transposeFunc,
+		"values":          stdlib.ValuesFunc,
+		"zipmap":          stdlib.ZipmapFunc,
+
+		// Encoding.
+		"base64decode": base64DecodeFunc,
+		"base64encode": base64EncodeFunc,
+		"base64gzip":   base64GzipFunc,
+		"csvdecode":    stdlib.CSVDecodeFunc,
+		"jsondecode":   stdlib.JSONDecodeFunc,
+		"jsonencode":   stdlib.JSONEncodeFunc,
+		"urlencode":    urlEncodeFunc,
+		"yamldecode":   yamlDecodeFunc,
+
+		// Type conversions.
+		"can":      tryfunc.CanFunc,
+		"tobool":   stdlib.MakeToFunc(cty.Bool),
+		"tolist":   stdlib.MakeToFunc(cty.List(cty.DynamicPseudoType)),
+		"tomap":    stdlib.MakeToFunc(cty.Map(cty.DynamicPseudoType)),
+		"tonumber": stdlib.MakeToFunc(cty.Number),
+		"toset":    stdlib.MakeToFunc(cty.Set(cty.DynamicPseudoType)),
+		"tostring": stdlib.MakeToFunc(cty.String),
+		"try":      tryfunc.TryFunc,
+
+		// Local data.
+		"env":  makeEnvFunc(environ),
+		"file": makeFileFunc(fs, dir),
+	}
+}
+
+// AllowFunctions lets the values collected from var files of fs in from call the functions of Functions.
+// Otherwise var files can only hold literal values, as in Terraform. The file function resolves relative
+// paths against the directory of the var file calling it, or dir for the values not read from a file,
+// e.g. StdinFilename.
+func AllowFunctions(from map[string]UnparsedVariableValue, fs afero.Fs, dir string, environ []string) {
+	ctxs := make(map[string]*hcl.EvalContext)
+
+	for name, v := range from {
+		e, ok := v.(unparsedVariableValueExpression)
+		if !ok {
+			continue
+		}
+
+		base := dir
+		if filename := e.expr.Range().Filename; filename != "" && filename != StdinFilename {
+			base = filepath.Dir(filename)
+		}
+
+		if ctxs[base] == nil {
+			ctxs[base] = &hcl.EvalContext{Functions: Functions(fs, base, environ)}
+		}
+
+		e.ctx = ctxs[base]
+		from[name] = e
+	}
+}
+
+var base64EncodeFunc = function.New(&function.Spec{
+	Params: []function.Parameter{{Name: "str", Type: cty.String}},
+	Type:   function.StaticReturnType(cty.String),
+	Impl: func(args []cty.Value, _ cty.Type) (cty.Value, error) {
+		return cty.StringVal(base64.StdEncoding.EncodeToString([]byte(args[0].AsString()))), nil
+	},
+})
+
+var base64DecodeFunc = function.New(&function.Spec{
+	Params: []function.Parameter{{Name: "str", Type: cty.String}},
+	Type:   function.StaticReturnType(cty.String),
+	Impl: func(args []cty.Value, _ cty.Type) (cty.Value, error) {
+		b, err := base64.StdEncoding.DecodeString(args[0].AsString())
+		if err != nil {
+			return cty.UnknownVal(cty.String), function.NewArgErrorf(0, "failed to decode base64 data: %s", err)
+		}
+
+		if !utf8.Valid(b) {
+			return cty.UnknownVal(cty.String), function.NewArgErrorf(0, "the decoded data is not valid UTF-8")
+		}
+
+		return cty.StringVal(string(b)), nil
+	},
+})
+
+var base64GzipFunc = function.New(&function.Spec{
+	Params: []function.Parameter{{Name: "str", Type: cty.String}},
+	Type:   function.StaticReturnType(cty.String),
+	Impl: func(args []cty.Value, _ cty.Type) (cty.Value, error) {
+		var buf bytes.Buffer
+
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write([]byte(args[0].AsString())); err != nil {
+			return cty.UnknownVal(cty.String), function.NewArgErrorf(0, "failed to compress: %s", err)
+		}
+
+		if err := w.Close(); err != nil {
+			return cty.UnknownVal(cty.String), function.NewArgErrorf(0, "failed to compress: %s", err)
+		}
+
+		return cty.StringVal(base64.StdEncoding.EncodeToString(buf.Bytes())), nil
+	},
+})
+
+var urlEncodeFunc = function.New(&function.Spec{
+	Params: []function.Parameter{{Name: "str", Type: cty.String}},
+	Type:   function.StaticReturnType(cty.String),
+	Impl: func(args []cty.Value, _ cty.Type) (cty.Value, error) {
+		return cty.StringVal(url.QueryEscape(args[0].AsString())), nil
+	},
+})
+
+// makeStringTestFunc returns a function that reports whether test is true for its two string arguments,
+// e.g. startswith.
+func makeStringTestFunc(second string, test func(s, t string) bool) function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{
+			{Name: "str", Type: cty.String},
+			{Name: second, Type: cty.String},
+		},
+		Type: function.StaticReturnType(cty.Bool),
+		Impl: func(args []cty.Value, _ cty.Type) (cty.Value, error) {
+			return cty.BoolVal(test(args[0].AsString(), args[1].AsString())), nil
+		},
+	})
+}
+
+var (
+	startsWithFunc  = makeStringTestFunc("prefix", strings.HasPrefix)
+	endsWithFunc    = makeStringTestFunc("suffix", strings.HasSuffix)
+	strContainsFunc = makeStringTestFunc("substr", strings.Contains)
+)
+
+var allTrueFunc = function.New(&function.Spec{
+	Params: []function.Parameter{{Name: "list", Type: cty.List(cty.Bool)}},
+	Type:   function.StaticReturnType(cty.Bool),
+	Impl: func(args []cty.Value, _ cty.Type) (cty.Value, error) {
+		for _, v := range args[0].AsValueSlice() {
+			if v.IsNull() || v.False() {
+				return cty.False, nil
+			}
+		}
+
+		return cty.True, nil
+	},
+})
+
+var anyTrueFunc = function.New(&function.Spec{
+	Params: []function.Parameter{{Name: "list", Type: cty.List(cty.Bool)}},
+	Type:   function.StaticReturnType(cty.Bool),
+	Impl: func(args []cty.Value, _ cty.Type) (cty.Value, error) {
+		for _, v := range args[0].AsValueSlice() {
+			if !v.IsNull() && v.True() {
+				return cty.True, nil
+			}
+		}
+
+		return cty.False, nil
+	},
+})
+
+var oneFunc = function.New(&function.Spec{
+	Params: []function.Parameter{{Name: "list", Type: cty.DynamicPseudoType}},
+	Type: func(args []cty.Value) (cty.Type, error) {
+		ty := args[0].Type()
+
+		switch {
+		case ty.IsListType() || ty.IsSetType():
+			return ty.ElementType(), nil
+		case ty.IsTupleType():
+			switch elems := ty.TupleElementTypes(); len(elems) {
+			case 0:
+				return cty.DynamicPseudoType, nil
+			case 1:
+				return elems[0], nil
+			}
+		}
+
+		return cty.NilType, function.NewArgErrorf(0, "must be a list, set, or tuple value with either zero or one elements")
+	},
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		switch elems := args[0].AsValueSlice(); len(elems) {
+		case 0:
+			return cty.NullVal(retType), nil
+		case 1:
+			return elems[0], nil
+		default:
+			return cty.NilVal, function.NewArgErrorf(0, "must be a list, set, or tuple value with either zero or one elements")
+		}
+	},
+})
+
+var sumFunc = function.New(&function.Spec{
+	Params: []function.Parameter{{Name: "list", Type: cty.DynamicPseudoType}},
+	Type:   function.StaticReturnType(cty.Number),
+	Impl: func(args []cty.Value, _ cty.Type) (cty.Value, error) {
+		ty := args[0].Type()
+		if !ty.IsListType() && !ty.IsSetType() && !ty.IsTupleType() {
+			return cty.NilVal, function.NewArgErrorf(0, "argument must be a list, set, or tuple of numbers")
+		}
+
+		elems := args[0].AsValueSlice()
+		if len(elems) == 0 {
+			return cty.NilVal, function.NewArgErrorf(0, "cannot sum an empty list")
+		}
+
+		sum := cty.Zero
+
+		for _, e := range elems {
+			n, err := convert.Convert(e, cty.Number)
+			if err != nil || n.IsNull() {
+				return cty.NilVal, function.NewArgErrorf(0, "argument must be a list, set, or tuple of numbers")
+			}
+
+			sum = sum.Add(n)
+		}
+
+		return sum, nil
+	},
+})
+
+var transposeFunc = function.New(&function.Spec{
+	Params: []function.Parameter{{Name: "values", Type: cty.Map(cty.List(cty.String))}},
+	Type:   function.StaticReturnType(cty.Map(cty.List(cty.String))),
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		transposed := make(map[string][]cty.Value)
+
+		// The keys of a map are iterated in lexical order.
+		for it := args[0].ElementIterator(); it.Next(); {
+			k, list := it.Element()
+			if list.IsNull() {
+				return cty.NilVal, function.NewArgErrorf(0, "lists must not be null")
+			}
+
+			for _, v := range list.AsValueSlice() {
+				if v.IsNull() {
+					return cty.NilVal, function.NewArgErrorf(0, "lists must not contain null values")
+				}
+
+				transposed[v.AsString()] = append(transposed[v.AsString()], k)
+			}
+		}
+
+		if len(transposed) == 0 {
+			return cty.MapValEmpty(cty.List(cty.String)), nil
+		}
+
+		result := make(map[string]cty.Value, len(transposed))
+		for k, keys := range transposed {
+			result[k] = cty.ListVal(keys)
+		}
+
+		return cty.MapVal(result), nil
+	},
+})
+
+var matchKeysFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{Name: "values", Type: cty.List(cty.DynamicPseudoType)},
+		{Name: "keys", Type: cty.List(cty.DynamicPseudoType)},
+		{Name: "searchset", Type: cty.List(cty.DynamicPseudoType)},
+	},
+	Type: func(args []cty.Value) (cty.Type, error) {
+		return cty.List(args[0].Type().ElementType()), nil
+	},
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		values, keys := args[0].AsValueSlice(), args[1].AsValueSlice()
+		if len(values) != len(keys) {
+			return cty.NilVal, function.NewArgErrorf(1, "length of keys and values should be equal")
+		}
+
+		var searchset []cty.Value
+
+		for _, s := range args[2].AsValueSlice() {
+			if len(keys) > 0 {
+				converted, err := convert.Convert(s, keys[0].Type())
+				if err != nil {
+					return cty.NilVal, function.NewArgErrorf(2, "searchset must be of the same type as keys: %s", err)
+				}
+
+				s = converted
+			}
+
+			searchset = append(searchset, s)
+		}
+
+		var matched []cty.Value
+
+		for i, k := range keys {
+			for _, s := range searchset {
+				if k.Equals(s).True() {
+					matched = append(matched, values[i])
+					break
+				}
+			}
+		}
+
+		if len(matched) == 0 {
+			return cty.ListValEmpty(retType.ElementType()), nil
+		}
+
+		return cty.ListVal(matched), nil
+	},
+})
+
+func makeFileFunc(fs afero.Fs, dir string) function.Function {
+	afs := newAfero(fs)
+
+	return function.New(&function.Spec{
+		Params: []function.Parameter{{Name: "path", Type: cty.String}},
+		Type:   function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, _ cty.Type) (cty.Value, error) {
+			path := args[0].AsString()
+
+			filename := path
+			if !filepath.IsAbs(filename) {
+				filename = filepath.Join(dir, filename)
+			}
+
+			b, err := afs.ReadFile(filename)
+			if err != nil {
+				return cty.UnknownVal(cty.String), function.NewArgErrorf(0, "failed to read %s: %s", path, err)
+			}
+
+			if !utf8.Valid(b) {
+				return cty.UnknownVal(cty.String), function.NewArgErrorf(0, "contents of %s are not valid UTF-8", path)
+			}
+
+			return cty.StringVal(string(b)), nil
+		},
+	})
+}
+
+func makeEnvFunc(environ []string) function.Function {
+	if environ == nil {
+		environ = os.Environ()
+	}
+
+	return function.New(&function.Spec{
+		Params: []function.Parameter{{Name: "name", Type: cty.String}},
+		Type:   function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, _ cty.Type) (cty.Value, error) {
+			name := args[0].AsString()
+			prefix := name + "="
+
+			for _, kv := range environ {
+				if strings.HasPrefix(kv, prefix) {
+					return cty.StringVal(kv[len(prefix):]), nil
+				}
+			}
+
+			return cty.UnknownVal(cty.String), function.NewArgErrorf(0, "environment variable %s is not set", name)
+		},
+	})
+}
+
+var yamlDecodeFunc = function.New(&function.Spec{
+	Params: []function.Parameter{{Name: "src", Type: cty.String}},
+	Type:   function.StaticReturnType(cty.DynamicPseudoType),
+	Impl: func(args []cty.Value, _ cty.Type) (cty.Value, error) {
+		var doc yaml.Node
+		if err := yaml.Unmarshal([]byte(args[0].AsString()), &doc); err != nil {
+			return cty.DynamicVal, function.NewArgErrorf(0, "failed to decode YAML: %s", err)
+		}
+
+		// An empty document is null.
+		if len(doc.Content) == 0 {
+			return cty.NullVal(cty.DynamicPseudoType), nil
+		}
+
+		val, err := yamlCtyValue(doc.Content[0])
+		if err != nil {
+			return cty.DynamicVal, function.NewArgErrorf(0, "failed to decode YAML: %s", err)
+		}
+
+		return val, nil
+	},
+})
+
+// yamlCtyValue returns node as a cty.Value, with objects for mappings and tuples for sequences as
+// jsondecode does. Scalars other than null, booleans, and numbers, e.g. timestamps, are strings.
+func yamlCtyValue(node *yaml.Node) (cty.Value, error) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		attrs := make(map[string]cty.Value, len(node.Content)/2)
+
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			val, err := yamlCtyValue(node.Content[i+1])
+			if err != nil {
+				return cty.NilVal, err
+			}
+
+			attrs[node.Content[i].Value] = val
+		}
+
+		return cty.ObjectVal(attrs), nil
+	case yaml.SequenceNode:
+		if len(node.Content) == 0 {
+			return cty.EmptyTupleVal, nil
+		}
+
+		elems := make([]cty.Value, 0, len(node.Content))
+
+		for _, n := range node.Content {
+			val, err := yamlCtyValue(n)
+			if err != nil {
+				return cty.NilVal, err
+			}
+
+			elems = append(elems, val)
+		}
+
+		return cty.TupleVal(elems), nil
+	case yaml.AliasNode:
+		return yamlCtyValue(node.Alias)
+	case yaml.ScalarNode:
+		return yamlScalarValue(node)
+	default:
+		return cty.NilVal, errors.Errorf("unsupported YAML value at line %d", node.Line)
+	}
+}
+
+func yamlScalarValue(node *yaml.Node) (cty.Value, error) {
+	switch node.ShortTag() {
+	case "!!null":
+		return cty.NullVal(cty.DynamicPseudoType), nil
+	case "!!bool":
+		var b bool
+		if err := node.Decode(&b); err != nil {
+			return cty.NilVal, err
+		}
+
+		return cty.BoolVal(b), nil
+	case "!!int":
+		var i int64
+		if err := node.Decode(&i); err == nil {
+			return cty.NumberIntVal(i), nil
+		}
+
+		// Out of the range of int64.
+		return cty.ParseNumberVal(node.Value)
+	case "!!float":
+		var f float64
+		if err := node.Decode(&f); err != nil {
+			return cty.NilVal, err
+		}
+
+		if math.IsNaN(f) {
+			return cty.NilVal, errors.Errorf("NaN at line %d is not a valid number", node.Line)
+		}
+
+		return cty.NumberFloatVal(f), nil
+	default:
+		return cty.StringVal(node.Value), nil
+	}
+}
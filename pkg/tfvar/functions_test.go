@@ -0,0 +1,216 @@
+package tfvar
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shihanng/tfvar/pkg/configs"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestAllowFunctions(t *testing.T) {
+	dir := t.TempDir()
+	data := filepath.Join(dir, "data.yaml")
+	require.NoError(t, ioutil.WriteFile(data, []byte("subnets:\n  - 10.0.0.0/24\n  - 10.0.1.0/24\n"), 0600))
+
+	tests := []struct {
+		name      string
+		src       string
+		want      cty.Value
+		assertion assert.ErrorAssertionFunc
+	}{
+		{
+			name: "yamldecode file",
+			src:  `a = yamldecode(file("` + filepath.ToSlash(data) + `")).subnets`,
+			want: cty.TupleVal([]cty.Value{
+				cty.StringVal("10.0.0.0/24"),
+				cty.StringVal("10.0.1.0/24"),
+			}),
+			assertion: assert.NoError,
+		},
+		{
+			name:      "jsondecode",
+			src:       `a = jsondecode("{\"b\": [1, true]}")`,
+			want:      cty.ObjectVal(map[string]cty.Value{"b": cty.TupleVal([]cty.Value{cty.NumberIntVal(1), cty.True})}),
+			assertion: assert.NoError,
+		},
+		{
+			name:      "base64",
+			src:       `a = base64decode(base64encode("secret"))`,
+			want:      cty.StringVal("secret"),
+			assertion: assert.NoError,
+		},
+		{
+			name:      "collections",
+			src:       `a = join("-", sort(distinct(concat(["b", "a"], ["a"]))))`,
+			want:      cty.StringVal("a-b"),
+			assertion: assert.NoError,
+		},
+		{
+			name:      "string tests",
+			src:       `a = [startswith("tfvar", "tf"), endswith("tfvar", "tf"), strcontains("tfvar", "va")]`,
+			want:      cty.TupleVal([]cty.Value{cty.True, cty.False, cty.True}),
+			assertion: assert.NoError,
+		},
+		{
+			name:      "alltrue anytrue",
+			src:       `a = [alltrue([true, true]), alltrue([true, false]), anytrue([false, true]), anytrue([])]`,
+			want:      cty.TupleVal([]cty.Value{cty.True, cty.False, cty.True, cty.False}),
+			assertion: assert.NoError,
+		},
+		{
+			name:      "one",
+			src:       `a = [one(["x"]), one([])]`,
+			want:      cty.TupleVal([]cty.Value{cty.StringVal("x"), cty.NullVal(cty.DynamicPseudoType)}),
+			assertion: assert.NoError,
+		},
+		{
+			name:      "one of many",
+			src:       `a = one(["x", "y"])`,
+			assertion: assert.Error,
+		},
+		{
+			name:      "sum",
+			src:       `a = sum([1, 2.5, "3"])`,
+			want:      cty.NumberFloatVal(6.5),
+			assertion: assert.NoError,
+		},
+		{
+			name: "transpose",
+			src:  `a = transpose({a = ["1", "2"], b = ["2"]})`,
+			want: cty.MapVal(map[string]cty.Value{
+				"1": cty.ListVal([]cty.Value{cty.StringVal("a")}),
+				"2": cty.ListVal([]cty.Value{cty.StringVal("a"), cty.StringVal("b")}),
+			}),
+			assertion: assert.NoError,
+		},
+		{
+			name:      "matchkeys",
+			src:       `a = matchkeys(["i-1", "i-2", "i-3"], ["us-west", "us-east", "us-east"], ["us-east"])`,
+			want:      cty.ListVal([]cty.Value{cty.StringVal("i-2"), cty.StringVal("i-3")}),
+			assertion: assert.NoError,
+		},
+		{
+			name:      "urlencode",
+			src:       `a = urlencode("a b&c")`,
+			want:      cty.StringVal("a+b%26c"),
+			assertion: assert.NoError,
+		},
+		{
+			name:      "try can",
+			src:       `a = [try(jsondecode("{"), "fallback"), can(tonumber("x"))]`,
+			want:      cty.TupleVal([]cty.Value{cty.StringVal("fallback"), cty.False}),
+			assertion: assert.NoError,
+		},
+		{
+			name:      "env",
+			src:       `a = env("TFVAR_FUNC")`,
+			want:      cty.StringVal("value"),
+			assertion: assert.NoError,
+		},
+		{
+			name:      "env not set",
+			src:       `a = env("TFVAR_NOT_SET")`,
+			assertion: assert.Error,
+		},
+		{
+			name:      "file relative to dir",
+			src:       `a = yamldecode(file("data.yaml")).subnets[0]`,
+			want:      cty.StringVal("10.0.0.0/24"),
+			assertion: assert.NoError,
+		},
+		{
+			name: "yamldecode scalars",
+			src:  `a = yamldecode("[1, 1.5, true, null, 2024-05-01, \"3\", 0x10]")`,
+			want: cty.TupleVal([]cty.Value{
+				cty.NumberIntVal(1),
+				cty.NumberFloatVal(1.5),
+				cty.True,
+				cty.NullVal(cty.DynamicPseudoType),
+				cty.StringVal("2024-05-01"),
+				cty.StringVal("3"),
+				cty.NumberIntVal(16),
+			}),
+			assertion: assert.NoError,
+		},
+		{
+			name:      "yamldecode NaN",
+			src:       `a = yamldecode(".nan")`,
+			assertion: assert.Error,
+		},
+		{
+			name:      "file not found",
+			src:       `a = file("` + filepath.ToSlash(filepath.Join(dir, "missing")) + `")`,
+			assertion: assert.Error,
+		},
+		{
+			name:      "unsafe function",
+			src:       `a = timestamp()`,
+			assertion: assert.Error,
+		},
+		{
+			name:      "variable reference",
+			src:       `a = var.b`,
+			assertion: assert.Error,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			from := make(map[string]UnparsedVariableValue)
+			require.NoError(t, CollectFromReader(strings.NewReader(tt.src), filepath.Join(dir, "functions.tfvars"), VarFileFormatHCL, from))
+
+			AllowFunctions(from, nil, dir, []string{"TFVAR_FUNC=value"})
+
+			actual, err := from["a"].ParseVariableValue(configs.VariableParseHCL)
+			tt.assertion(t, err)
+
+			if tt.want != cty.NilVal {
+				assert.True(t, tt.want.RawEquals(actual), "want %#v, got %#v", tt.want, actual)
+			}
+		})
+	}
+}
+
+func TestAllowFunctionsFS(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/module/env/prod.tfvars", []byte(`a = file("data.txt")`), 0644))
+	require.NoError(t, afero.WriteFile(fs, "/module/env/data.txt", []byte("from env"), 0644))
+	require.NoError(t, afero.WriteFile(fs, "/module/data.txt", []byte("from module"), 0644))
+
+	from := make(map[string]UnparsedVariableValue)
+	require.NoError(t, CollectFromFileFS(fs, "/module/env/prod.tfvars", from))
+	require.NoError(t, CollectFromReader(strings.NewReader(`b = file("data.txt")`), StdinFilename, VarFileFormatHCL, from))
+
+	AllowFunctions(from, fs, "/module", nil)
+
+	actual, err := from["a"].ParseVariableValue(configs.VariableParseHCL)
+	require.NoError(t, err)
+	assert.Equal(t, cty.StringVal("from env"), actual)
+
+	actual, err = from["b"].ParseVariableValue(configs.VariableParseHCL)
+	require.NoError(t, err)
+	assert.Equal(t, cty.StringVal("from module"), actual)
+}
+
+func TestFunctions(t *testing.T) {
+	funcs := Functions(nil, ".", nil)
+
+	for _, name := range []string{
+		"file", "jsondecode", "yamldecode", "base64encode", "base64gzip", "urlencode", "env", "upper", "merge",
+		"startswith", "endswith", "strcontains", "alltrue", "anytrue", "one", "sum", "transpose", "matchkeys",
+		"try", "can",
+	} {
+		assert.Contains(t, funcs, name)
+	}
+
+	for _, name := range []string{"timestamp", "uuid", "templatefile", "fileset"} {
+		assert.NotContains(t, funcs, name)
+	}
+}
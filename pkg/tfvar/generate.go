@@ -0,0 +1,354 @@
+package tfvar
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/cockroachdb/errors"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/spf13/afero"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Options control how Generate loads the variables of a module and assigns their values.
+// The zero value, apart from Dir, loads the variables with their default values, as the
+// tfvar command does without any flags.
+type Options struct {
+	// FS is the filesystem Dir is read from. If nil then the system's "real" filesystem will be used,
+	// and Dir may also refer to a module archive supported by OpenArchive.
+	FS afero.Fs
+	// Dir is the directory of the root module.
+	Dir string
+	// Module is the key of a module installed by terraform init in Dir, e.g. "vpc", whose variables are
+	// loaded instead of the ones of the root module. See LookupInstalledModule. The values are still
+	// assigned from the var files of the root module.
+	Module string
+
+	// Tofu selects whether OpenTofu's rules are followed to select the configuration files in Dir,
+	// i.e. .tofu and .tofu.json files are read and take precedence over the .tf and .tf.json files of
+	// the same name. If nil then OpenTofu's rules are followed when Dir contains any .tofu or .tofu.json file.
+	Tofu *bool
+	// Lenient loads the variables even if the rest of the configurations have errors,
+	// which are returned as warnings. See LoadFSLenient.
+	Lenient bool
+
+	// IgnoreDefault replaces the default values of the variables with null.
+	IgnoreDefault bool
+	// AutoAssign assigns the values of the TF_VAR_* environment variables, terraform.tfvars[.json],
+	// and *.auto.tfvars[.json] files, as Terraform does.
+	AutoAssign bool
+	// Environ is the environment, in the "key=value" form of os.Environ, used by AutoAssign and
+	// TFCLICommand, and to look up the age keys of the var files encrypted with SOPS.
+	// If nil then the environment of the current process will be used.
+	Environ []string
+	// TFCLICommand assigns the values of the -var and -var-file options Terraform reads from the
+	// TF_CLI_ARGS and TF_CLI_ARGS_<command> environment variables for the given command, e.g. "plan".
+	// See ParseTFCLIArgs. Relative var files are resolved against Dir and read from FS.
+	TFCLICommand string
+	// OutputsFile assigns the outputs read from the output of terraform output -json or a local
+	// state file to the variables with the same names, or the ones selected by OutputMappingFile.
+	// See ReadOutputsFile and CollectFromOutputs.
+	OutputsFile string
+	// OutputMappingFile maps variables to the outputs of OutputsFile, see ReadOutputMappingFile.
+	OutputMappingFile string
+	// PlanFile assigns the values a plan was made with from the JSON representation of the saved plan,
+	// output by terraform show -json. See CollectFromPlanFile.
+	PlanFile string
+	// VarArgs are the -var and -var-file options given on the command line, in order.
+	// Relative var files are resolved against the current working directory. The var file
+	// StdinVarFile is read from Stdin.
+	VarArgs []VarArg
+	// Stdin is read by the StdinVarFile of VarArgs. If nil then the standard input will be used.
+	Stdin io.Reader
+	// StdinFormat is the format of Stdin, see CollectFromReader.
+	StdinFormat string
+	// AllowFunctions lets the var files call the functions of Functions, with the environment Environ.
+	// The file function reads the files relative to the directory of the var file, or Dir for Stdin.
+	AllowFunctions bool
+}
+
+// Warning is a problem found by Generate that does not prevent generating the variables.
+type Warning struct {
+	// Variable is the name of the variable the warning is about, if any.
+	Variable string
+	Message  string
+	// Diagnostic is the HCL diagnostic the warning is from, if any.
+	Diagnostic *hcl.Diagnostic
+}
+
+func (w Warning) String() string {
+	if w.Variable == "" {
+		return w.Message
+	}
+
+	return fmt.Sprintf("%s: %s", w.Variable, w.Message)
+}
+
+// Result is the outcome of Generate.
+type Result struct {
+	// Variables are the variables of the module sorted by name, with the values assigned
+	// from the sources selected in Options.
+	Variables []Variable
+	Warnings  []Warning
+}
+
+// Generate loads the variables of the module in opts.Dir and assigns their values from the sources
+// selected in opts, in the same order as Terraform and the tfvar command:
+//  1. default values, unless IgnoreDefault is set,
+//  2. environment variables, then terraform.tfvars[.json] and *.auto.tfvars[.json] files, if AutoAssign is set,
+//  3. TF_CLI_ARGS_<command> and TF_CLI_ARGS, if TFCLICommand is set,
+//  4. the outputs of OutputsFile, if set,
+//  5. the values of PlanFile, if set,
+//  6. VarArgs.
+//
+// Later sources take precedence over earlier ones. The result can be written with any Writer, e.g.
+//
+//	res, _ := tfvar.Generate(ctx, tfvar.Options{Dir: ".", AutoAssign: true})
+//	w, _ := tfvar.LookupWriter(tfvar.FormatTFVars)
+//	_ = w.Write(os.Stdout, res.Variables)
+func Generate(ctx context.Context, opts Options) (Result, error) {
+	if opts.Dir == "" {
+		return Result{}, errors.New("tfvar: no module directory given")
+	}
+
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
+
+	fs, dir := opts.FS, opts.Dir
+
+	// isArchive is true when Dir refers to an archive, whose var files are not looked up inside the archive.
+	var isArchive bool
+
+	if fs == nil && IsArchive(dir) {
+		var err error
+
+		fs, dir, err = OpenArchive(dir)
+		if err != nil {
+			return Result{}, err
+		}
+
+		isArchive = true
+	}
+
+	// The variables are loaded from the installed Module, but the values are still looked up
+	// in the root module, as Terraform does.
+	moduleDir := dir
+
+	if opts.Module != "" {
+		m, err := LookupInstalledModule(fs, dir, opts.Module)
+		if err != nil {
+			return Result{}, err
+		}
+
+		moduleDir = m.Dir
+	}
+
+	vars, warnings, err := loadFS(fs, moduleDir, opts)
+	if err != nil {
+		return Result{}, err
+	}
+
+	sort.Slice(vars, func(i, j int) bool { return vars[i].Name < vars[j].Name })
+
+	if opts.IgnoreDefault {
+		for i, v := range vars {
+			vars[i].Value = cty.NullVal(v.Value.Type())
+		}
+	}
+
+	environ := opts.Environ
+	if environ == nil {
+		environ = os.Environ()
+	}
+
+	unparseds := make(map[string]UnparsedVariableValue)
+
+	// assigned are the variables assigned by other sources than environment variables,
+	// which Terraform warns about when they are not declared.
+	assigned := make(map[string]struct{})
+
+	// collect adds the values read by f from the var files of fsys.
+	collect := func(fsys afero.Fs, f func(map[string]UnparsedVariableValue) error) error {
+		to := make(map[string]UnparsedVariableValue)
+		if err := f(to); err != nil {
+			return err
+		}
+
+		if opts.AllowFunctions {
+			AllowFunctions(to, fsys, dir, environ)
+		}
+
+		for name, v := range to {
+			unparseds[name] = v
+			assigned[name] = struct{}{}
+		}
+
+		return ctx.Err()
+	}
+
+	if opts.AutoAssign {
+		CollectFromEnviron(environ, unparseds)
+
+		for _, f := range LookupTFVarsFilesFS(fs, dir) {
+			f := f
+			if err := collect(fs, func(to map[string]UnparsedVariableValue) error {
+				return collectFromFileEnviron(fs, f, environ, to)
+			}); err != nil {
+				return Result{}, err
+			}
+		}
+	}
+
+	if opts.TFCLICommand != "" {
+		cliArgs, err := ParseTFCLIArgs(environ, opts.TFCLICommand)
+		if err != nil {
+			return Result{}, err
+		}
+
+		// Terraform resolves -var-file relative to the directory it runs in, i.e. the root module.
+		// Var files are not looked up inside archives.
+		for i, a := range cliArgs {
+			if a.Kind == VarArgVarFile && !isArchive && !filepath.IsAbs(a.Value) {
+				cliArgs[i].Value = filepath.Join(dir, a.Value)
+			}
+		}
+
+		if err := collect(opts.FS, func(to map[string]UnparsedVariableValue) error {
+			return collectFromVarArgsEnviron(opts.FS, cliArgs, environ, to)
+		}); err != nil {
+			return Result{}, err
+		}
+	}
+
+	if opts.OutputsFile != "" {
+		if err := collect(nil, func(to map[string]UnparsedVariableValue) error {
+			return collectFromOutputs(opts, vars, to)
+		}); err != nil {
+			return Result{}, err
+		}
+	}
+
+	if opts.PlanFile != "" {
+		if err := collect(nil, func(to map[string]UnparsedVariableValue) error {
+			return CollectFromPlanFile(opts.PlanFile, to)
+		}); err != nil {
+			return Result{}, err
+		}
+	}
+
+	if err := collect(nil, func(to map[string]UnparsedVariableValue) error {
+		return collectFromVarArgs(opts, environ, to)
+	}); err != nil {
+		return Result{}, err
+	}
+
+	vars, err = ParseValues(unparseds, vars)
+	if err != nil {
+		return Result{}, err
+	}
+
+	warnings = append(warnings, undeclaredWarnings(assigned, vars)...)
+	warnings = append(warnings, deprecatedWarnings(unparseds, vars)...)
+
+	return Result{
+		Variables: vars,
+		Warnings:  warnings,
+	}, nil
+}
+
+// collectFromVarArgs is like CollectFromVarArgs for opts.VarArgs but reads the StdinVarFile from opts.Stdin
+// and decrypts the var files with the age keys of environ.
+func collectFromVarArgs(opts Options, environ []string, to map[string]UnparsedVariableValue) error {
+	var isStdinRead bool
+
+	for _, arg := range opts.VarArgs {
+		if arg.Kind != VarArgVarFile || arg.Value != StdinVarFile {
+			if err := collectFromVarArgsEnviron(nil, []VarArg{arg}, environ, to); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if isStdinRead {
+			return errors.Errorf("tfvar: -var-file %s can only be given once", StdinVarFile)
+		}
+
+		isStdinRead = true
+
+		stdin := opts.Stdin
+		if stdin == nil {
+			stdin = os.Stdin
+		}
+
+		if err := CollectFromReader(stdin, StdinFilename, opts.StdinFormat, to); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// collectFromOutputs reads opts.OutputsFile and opts.OutputMappingFile for CollectFromOutputs.
+func collectFromOutputs(opts Options, vars []Variable, to map[string]UnparsedVariableValue) error {
+	outputs, err := ReadOutputsFile(opts.OutputsFile)
+	if err != nil {
+		return err
+	}
+
+	var mapping OutputMapping
+
+	if opts.OutputMappingFile != "" {
+		mapping, err = ReadOutputMappingFile(opts.OutputMappingFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	return CollectFromOutputs(outputs, mapping, vars, to)
+}
+
+// deprecatedWarnings returns a warning for each of the deprecated vars that is assigned a value in from,
+// as OpenTofu does.
+func deprecatedWarnings(from map[string]UnparsedVariableValue, vars []Variable) []Warning {
+	var warnings []Warning
+
+	for _, v := range vars {
+		if _, found := from[v.Name]; found && v.Deprecated != "" {
+			warnings = append(warnings, Warning{
+				Variable: v.Name,
+				Message:  "variable is deprecated: " + v.Deprecated,
+			})
+		}
+	}
+
+	return warnings
+}
+
+// undeclaredWarnings returns a warning for each of the assigned names that is not declared in vars.
+func undeclaredWarnings(assigned map[string]struct{}, vars []Variable) []Warning {
+	declared := make(map[string]struct{}, len(vars))
+	for _, v := range vars {
+		declared[v.Name] = struct{}{}
+	}
+
+	var warnings []Warning
+
+	for name := range assigned {
+		if _, found := declared[name]; !found {
+			warnings = append(warnings, Warning{
+				Variable: name,
+				Message:  "value assigned to undeclared variable",
+			})
+		}
+	}
+
+	sort.Slice(warnings, func(i, j int) bool { return warnings[i].Variable < warnings[j].Variable })
+
+	return warnings
+}
@@ -0,0 +1,315 @@
+package tfvar
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestGenerate(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/module/main.tf", []byte(`
+variable "region" {
+  default = "us-east-1"
+}
+
+variable "zone" {}
+
+variable "image_id" {
+  type = string
+}
+
+variable "instances" {
+  type    = number
+  default = 1
+}
+`), 0644))
+	require.NoError(t, afero.WriteFile(fs, "/module/terraform.tfvars", []byte(`
+zone    = "from-file"
+unknown = "x"
+`), 0644))
+
+	environ := []string{
+		"TF_VAR_zone=from-env",
+		"TF_VAR_image_id=from-env",
+		"TF_VAR_not_declared=ignored",
+		"TF_CLI_ARGS_plan=-var image_id=from-cli-args",
+	}
+
+	tests := []struct {
+		name         string
+		opts         Options
+		want         map[string]cty.Value
+		wantWarnings []Warning
+		assertion    assert.ErrorAssertionFunc
+	}{
+		{
+			name: "defaults",
+			opts: Options{FS: fs, Dir: "/module", Environ: environ},
+			want: map[string]cty.Value{
+				"instances": cty.NumberIntVal(1),
+				"image_id":  cty.NilVal,
+				"region":    cty.StringVal("us-east-1"),
+				"zone":      cty.NilVal,
+			},
+			assertion: assert.NoError,
+		},
+		{
+			name: "all sources",
+			opts: Options{
+				FS:            fs,
+				Dir:           "/module",
+				IgnoreDefault: true,
+				AutoAssign:    true,
+				Environ:       environ,
+				TFCLICommand:  "plan",
+				VarArgs:       []VarArg{{Kind: VarArgVar, Value: "instances=3"}},
+			},
+			want: map[string]cty.Value{
+				"instances": cty.StringVal("3"),
+				"image_id":  cty.StringVal("from-cli-args"),
+				"region":    cty.NullVal(cty.String),
+				"zone":      cty.StringVal("from-file"),
+			},
+			wantWarnings: []Warning{
+				{Variable: "unknown", Message: "value assigned to undeclared variable"},
+			},
+			assertion: assert.NoError,
+		},
+		{
+			name: "plan",
+			opts: Options{
+				FS:         fs,
+				Dir:        "/module",
+				AutoAssign: true,
+				Environ:    environ,
+				PlanFile:   "testdata/plan.json",
+				VarArgs:    []VarArg{{Kind: VarArgVar, Value: "image_id=from-var"}},
+			},
+			want: map[string]cty.Value{
+				"instances": cty.NumberIntVal(3),
+				"image_id":  cty.StringVal("from-var"),
+				"region":    cty.StringVal("us-east-1"),
+				"zone":      cty.StringVal("from-file"),
+			},
+			wantWarnings: []Warning{
+				{Variable: "tags", Message: "value assigned to undeclared variable"},
+				{Variable: "unknown", Message: "value assigned to undeclared variable"},
+			},
+			assertion: assert.NoError,
+		},
+		{
+			name: "stdin",
+			opts: Options{
+				FS:      fs,
+				Dir:     "/module",
+				Environ: environ,
+				VarArgs: []VarArg{
+					{Kind: VarArgVar, Value: "zone=from-var"},
+					{Kind: VarArgVarFile, Value: StdinVarFile},
+				},
+				Stdin: strings.NewReader(`{"zone": "from-stdin"}`),
+			},
+			want: map[string]cty.Value{
+				"instances": cty.NumberIntVal(1),
+				"image_id":  cty.NilVal,
+				"region":    cty.StringVal("us-east-1"),
+				"zone":      cty.StringVal("from-stdin"),
+			},
+			assertion: assert.NoError,
+		},
+		{
+			name: "functions",
+			opts: Options{
+				FS:             fs,
+				Dir:            "/module",
+				Environ:        []string{"ZONE=a"},
+				VarArgs:        []VarArg{{Kind: VarArgVarFile, Value: StdinVarFile}},
+				Stdin:          strings.NewReader(`zone = upper(env("ZONE"))`),
+				AllowFunctions: true,
+			},
+			want: map[string]cty.Value{
+				"instances": cty.NumberIntVal(1),
+				"image_id":  cty.NilVal,
+				"region":    cty.StringVal("us-east-1"),
+				"zone":      cty.StringVal("A"),
+			},
+			assertion: assert.NoError,
+		},
+		{
+			name: "functions not allowed",
+			opts: Options{
+				FS:      fs,
+				Dir:     "/module",
+				Environ: []string{"ZONE=a"},
+				VarArgs: []VarArg{{Kind: VarArgVarFile, Value: StdinVarFile}},
+				Stdin:   strings.NewReader(`zone = upper(env("ZONE"))`),
+			},
+			assertion: assert.Error,
+		},
+		{
+			name: "stdin twice",
+			opts: Options{
+				FS:      fs,
+				Dir:     "/module",
+				VarArgs: []VarArg{{Kind: VarArgVarFile, Value: StdinVarFile}, {Kind: VarArgVarFile, Value: StdinVarFile}},
+				Stdin:   strings.NewReader(""),
+			},
+			assertion: assert.Error,
+		},
+		{
+			name:      "no dir",
+			opts:      Options{FS: fs},
+			assertion: assert.Error,
+		},
+		{
+			name:      "bad var arg",
+			opts:      Options{FS: fs, Dir: "/module", VarArgs: []VarArg{{Kind: VarArgVar, Value: "instances"}}},
+			assertion: assert.Error,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := Generate(context.Background(), tt.opts)
+			tt.assertion(t, err)
+
+			if tt.want == nil {
+				return
+			}
+
+			got := make(map[string]cty.Value, len(res.Variables))
+			names := make([]string, 0, len(res.Variables))
+
+			for _, v := range res.Variables {
+				got[v.Name] = v.Value
+				names = append(names, v.Name)
+			}
+
+			assert.Equal(t, []string{"image_id", "instances", "region", "zone"}, names)
+
+			for name, want := range tt.want {
+				assert.True(t, want.RawEquals(got[name]), "%s: want %#v, got %#v", name, want, got[name])
+			}
+
+			assert.Equal(t, tt.wantWarnings, res.Warnings)
+		})
+	}
+}
+
+func TestGenerateModule(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	files := map[string]string{
+		"/root/main.tf": `module "vpc" {
+  source = "./modules/vpc"
+}
+`,
+		"/root/terraform.tfvars": `cidr = "from-root-tfvars"`,
+		"/root/cli.tfvars":       `name = "from-root-cli-args"`,
+		"/root/.terraform/modules/modules.json": `{"Modules": [
+  {"Key": "", "Source": "", "Dir": "."},
+  {"Key": "vpc", "Source": "./modules/vpc", "Dir": "modules/vpc"}
+]}`,
+		"/root/modules/vpc/main.tf": `
+variable "cidr" {}
+variable "name" {}
+variable "zone" {}
+`,
+		// Terraform never reads the var files of a child module.
+		"/root/modules/vpc/terraform.tfvars": `zone = "from-module-tfvars"`,
+		"/root/modules/vpc/cli.tfvars":       `name = "from-module-cli-args"`,
+	}
+
+	for name, src := range files {
+		require.NoError(t, afero.WriteFile(fs, name, []byte(src), 0644))
+	}
+
+	res, err := Generate(context.Background(), Options{
+		FS:           fs,
+		Dir:          "/root",
+		Module:       "vpc",
+		AutoAssign:   true,
+		Environ:      []string{"TF_CLI_ARGS_plan=-var-file=cli.tfvars"},
+		TFCLICommand: "plan",
+	})
+	require.NoError(t, err)
+
+	got := make(map[string]cty.Value, len(res.Variables))
+	for _, v := range res.Variables {
+		got[v.Name] = v.Value
+	}
+
+	assert.Equal(t, map[string]cty.Value{
+		"cidr": cty.StringVal("from-root-tfvars"),
+		"name": cty.StringVal("from-root-cli-args"),
+		"zone": cty.NilVal,
+	}, got)
+}
+
+func TestGenerateCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := Generate(ctx, Options{Dir: "testdata/defaults"})
+	assert.True(t, errors.Is(err, context.Canceled))
+}
+
+func TestGenerateTofu(t *testing.T) {
+	off := false
+
+	tests := []struct {
+		name         string
+		opts         Options
+		want         map[string]cty.Value
+		wantWarnings []Warning
+	}{
+		{
+			name: "auto",
+			opts: Options{
+				Dir:     "testdata/tofu",
+				VarArgs: []VarArg{{Kind: VarArgVar, Value: "zone=a"}},
+			},
+			want: map[string]cty.Value{
+				"region": cty.StringVal("eu-central-1"),
+				"zone":   cty.StringVal("a"),
+			},
+			wantWarnings: []Warning{
+				{Variable: "zone", Message: "variable is deprecated: Use region instead."},
+			},
+		},
+		{
+			name: "off",
+			opts: Options{
+				Dir:  "testdata/tofu",
+				Tofu: &off,
+			},
+			want: map[string]cty.Value{
+				"region": cty.StringVal("us-east-1"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := Generate(context.Background(), tt.opts)
+			require.NoError(t, err)
+
+			got := make(map[string]cty.Value, len(res.Variables))
+			for _, v := range res.Variables {
+				got[v.Name] = v.Value
+			}
+
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantWarnings, res.Warnings)
+		})
+	}
+}
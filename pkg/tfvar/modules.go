@@ -0,0 +1,82 @@
+package tfvar
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/spf13/afero"
+)
+
+// moduleManifestPath is where terraform init records the installed modules, relative to the root module.
+var moduleManifestPath = filepath.Join(".terraform", "modules", "modules.json")
+
+// InstalledModule describes a module installed by terraform init, as recorded in .terraform/modules/modules.json.
+type InstalledModule struct {
+	// Key is the path of module calls from the root module, e.g. "vpc" or "vpc.subnets".
+	// The root module itself has an empty Key.
+	Key     string
+	Source  string
+	Version string
+	// Dir is the directory the module is installed in, including the directory of the root module.
+	Dir string
+}
+
+type moduleManifest struct {
+	Modules []InstalledModule `json:"Modules"`
+}
+
+// LoadModuleManifest reads the modules installed by terraform init for the root module in dir.
+// Registry and git modules can then be loaded from InstalledModule.Dir without network access, e.g.
+//    modules, _ := tfvar.LoadModuleManifest(nil, ".")
+//    vars, _ := tfvar.LoadFS(nil, modules["vpc"].Dir)
+// If a nil filesystem is passed then the system's "real" filesystem will be used.
+func LoadModuleManifest(fs afero.Fs, dir string) (map[string]InstalledModule, error) {
+	filename := filepath.Join(dir, moduleManifestPath)
+
+	src, err := newAfero(fs).ReadFile(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "tfvar: reading module manifest '%s', run terraform init first", filename)
+	}
+
+	var manifest moduleManifest
+
+	if err := json.Unmarshal(src, &manifest); err != nil {
+		return nil, errors.Wrapf(err, "tfvar: failed to parse '%s'", filename)
+	}
+
+	modules := make(map[string]InstalledModule, len(manifest.Modules))
+
+	for _, m := range manifest.Modules {
+		m.Dir = filepath.Join(dir, filepath.FromSlash(m.Dir))
+		modules[m.Key] = m
+	}
+
+	return modules, nil
+}
+
+// LookupInstalledModule returns the installed module of the given key in the manifest of the root module in dir.
+func LookupInstalledModule(fs afero.Fs, dir, key string) (InstalledModule, error) {
+	modules, err := LoadModuleManifest(fs, dir)
+	if err != nil {
+		return InstalledModule{}, err
+	}
+
+	m, found := modules[key]
+	if !found || key == "" {
+		keys := make([]string, 0, len(modules))
+		for k := range modules {
+			if k != "" {
+				keys = append(keys, k)
+			}
+		}
+
+		sort.Strings(keys)
+
+		return InstalledModule{}, errors.Errorf("tfvar: module '%s' is not installed, must be one of: %s", key, strings.Join(keys, ", "))
+	}
+
+	return m, nil
+}
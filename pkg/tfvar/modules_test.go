@@ -0,0 +1,45 @@
+package tfvar
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadModuleManifest(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/root/.terraform/modules/modules.json", []byte(`{
+  "Modules": [
+    {"Key": "", "Source": "", "Dir": "."},
+    {"Key": "vpc", "Source": "registry.terraform.io/terraform-aws-modules/vpc/aws", "Version": "5.0.0", "Dir": ".terraform/modules/vpc"},
+    {"Key": "vpc.subnets", "Source": "./modules/subnets", "Dir": ".terraform/modules/vpc/modules/subnets"}
+  ]
+}`), 0644))
+	require.NoError(t, afero.WriteFile(fs, "/bad/.terraform/modules/modules.json", []byte(`{`), 0644))
+
+	got, err := LoadModuleManifest(fs, "/root")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]InstalledModule{
+		"":            {Dir: "/root"},
+		"vpc":         {Key: "vpc", Source: "registry.terraform.io/terraform-aws-modules/vpc/aws", Version: "5.0.0", Dir: "/root/.terraform/modules/vpc"},
+		"vpc.subnets": {Key: "vpc.subnets", Source: "./modules/subnets", Dir: "/root/.terraform/modules/vpc/modules/subnets"},
+	}, got)
+
+	_, err = LoadModuleManifest(fs, "/bad")
+	assert.Error(t, err)
+
+	_, err = LoadModuleManifest(fs, "/unknown")
+	assert.Error(t, err)
+
+	m, err := LookupInstalledModule(fs, "/root", "vpc.subnets")
+	require.NoError(t, err)
+	assert.Equal(t, "/root/.terraform/modules/vpc/modules/subnets", m.Dir)
+
+	_, err = LookupInstalledModule(fs, "/root", "unknown")
+	assert.EqualError(t, err, "tfvar: module 'unknown' is not installed, must be one of: vpc, vpc.subnets")
+
+	_, err = LookupInstalledModule(fs, "/root", "")
+	assert.Error(t, err)
+}
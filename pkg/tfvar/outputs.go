@@ -0,0 +1,264 @@
+package tfvar
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/shihanng/tfvar/pkg/configs"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// Outputs are the values of the outputs of a Terraform configuration by name.
+type Outputs map[string]cty.Value
+
+// outputJSON is an output in both the output of terraform output -json and the outputs of a state file.
+type outputJSON struct {
+	Value json.RawMessage `json:"value"`
+	Type  json.RawMessage `json:"type"`
+}
+
+// ReadOutputsFile reads the outputs from filename, which is either the output of terraform output -json
+// or a local state file (.tfstate).
+func ReadOutputsFile(filename string) (Outputs, error) {
+	src, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "tfvar: reading file '%s'", filename)
+	}
+
+	return ParseOutputs(src, filename)
+}
+
+// ParseOutputs is like ReadOutputsFile but parses src, the content of filename.
+func ParseOutputs(src []byte, filename string) (Outputs, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(src, &raw); err != nil {
+		return nil, errors.Wrapf(err, "tfvar: failed to parse outputs '%s'", filename)
+	}
+
+	outputs := raw
+
+	if rawOutputs, found := raw["outputs"]; found && isState(raw) {
+		outputs = nil
+		if err := json.Unmarshal(rawOutputs, &outputs); err != nil {
+			return nil, errors.Wrapf(err, "tfvar: failed to parse outputs of state '%s'", filename)
+		}
+	}
+
+	result := make(Outputs, len(outputs))
+
+	for name, rawOutput := range outputs {
+		var o outputJSON
+		if err := json.Unmarshal(rawOutput, &o); err != nil || o.Value == nil {
+			return nil, errors.Errorf("tfvar: '%s' is neither the output of terraform output -json nor a state file", filename)
+		}
+
+		val, err := o.value()
+		if err != nil {
+			return nil, errors.Wrapf(err, "tfvar: failed to parse output %s of '%s'", name, filename)
+		}
+
+		result[name] = val
+	}
+
+	return result, nil
+}
+
+// isState reports whether raw is a state file rather than the output of terraform output -json.
+// A state file has its outputs next to the Terraform version and lineage strings, while each output
+// of terraform output -json, which can be named e.g. version too, is an object with a value.
+func isState(raw map[string]json.RawMessage) bool {
+	for _, key := range []string{"terraform_version", "lineage"} {
+		var s string
+		if err := json.Unmarshal(raw[key], &s); err == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (o outputJSON) value() (cty.Value, error) {
+	var (
+		ty  cty.Type
+		err error
+	)
+
+	if o.Type != nil {
+		ty, err = ctyjson.UnmarshalType(o.Type)
+	} else {
+		ty, err = ctyjson.ImpliedType(o.Value)
+	}
+
+	if err != nil {
+		return cty.NilVal, err
+	}
+
+	return ctyjson.Unmarshal(o.Value, ty)
+}
+
+// OutputMapping maps the names of variables to the outputs, or the parts of them, assigned to them.
+type OutputMapping map[string]hcl.Traversal
+
+// ReadOutputMappingFile reads an OutputMapping from filename. Each line of the file maps a variable to
+// an output, or an attribute or element of it written as an HCL traversal, e.g.
+//    # variable <- output
+//    vpc_id     <- network.vpc_id
+//    subnet_ids <- private_subnet_ids
+//    first_az   <- azs[0]
+// Empty lines and lines starting with # are ignored.
+func ReadOutputMappingFile(filename string) (OutputMapping, error) {
+	src, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "tfvar: reading file '%s'", filename)
+	}
+
+	return ParseOutputMapping(src, filename)
+}
+
+// ParseOutputMapping is like ReadOutputMappingFile but parses src, the content of filename.
+func ParseOutputMapping(src []byte, filename string) (OutputMapping, error) {
+	files := map[string]*hcl.File{filename: {Bytes: src}}
+	mapping := make(OutputMapping)
+
+	var (
+		diags  hcl.Diagnostics
+		offset int
+	)
+
+	for i, line := range bytes.SplitAfter(src, []byte("\n")) {
+		pos := hcl.Pos{Line: i + 1, Column: 1, Byte: offset}
+		offset += len(line)
+
+		text := strings.TrimSpace(string(line))
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		arrow := bytes.Index(line, []byte("<-"))
+		if arrow < 0 {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid output mapping",
+				Detail:   `Each line must map a variable to an output as "variable <- output".`,
+				Subject:  &hcl.Range{Filename: filename, Start: pos, End: hcl.Pos{Line: pos.Line, Column: len(line), Byte: offset}},
+			})
+
+			continue
+		}
+
+		name := strings.TrimSpace(string(line[:arrow]))
+		if !hclsyntax.ValidIdentifier(name) {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid variable name",
+				Detail:   fmt.Sprintf("%q is not a valid variable name.", name),
+				Subject:  &hcl.Range{Filename: filename, Start: pos, End: hcl.Pos{Line: pos.Line, Column: arrow + 1, Byte: pos.Byte + arrow}},
+			})
+
+			continue
+		}
+
+		start := arrow + len("<-")
+		traversal, travDiags := hclsyntax.ParseTraversalAbs(
+			bytes.TrimRight(line[start:], " \t\r\n"), filename, hcl.Pos{Line: pos.Line, Column: start + 1, Byte: pos.Byte + start},
+		)
+		diags = append(diags, travDiags...)
+
+		if !travDiags.HasErrors() {
+			mapping[name] = traversal
+		}
+	}
+
+	if diags.HasErrors() {
+		return nil, newDiagnosticsError(fmt.Sprintf("tfvar: failed to parse output mapping '%s'", filename), diags, files)
+	}
+
+	return mapping, nil
+}
+
+// CollectFromOutputs assigns the outputs to the vars with the same names, or the ones selected by mapping
+// if not nil. The values are converted to the types of the vars.
+func CollectFromOutputs(outputs Outputs, mapping OutputMapping, vars []Variable, to map[string]UnparsedVariableValue) error {
+	types := make(map[string]cty.Type, len(vars))
+	for _, v := range vars {
+		types[v.Name] = v.Type
+	}
+
+	if mapping == nil {
+		mapping = make(OutputMapping)
+
+		for name := range outputs {
+			if _, found := types[name]; found {
+				mapping[name] = hcl.Traversal{hcl.TraverseRoot{Name: name}}
+			}
+		}
+	}
+
+	names := make([]string, 0, len(mapping))
+	for name := range mapping {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	ctx := &hcl.EvalContext{Variables: outputs}
+
+	for _, name := range names {
+		traversal := mapping[name]
+
+		val, diags := traversal.TraverseAbs(ctx)
+		if diags.HasErrors() {
+			return errors.Wrapf(diags, "tfvar: failed to get output %s for var.%s", traversalString(traversal), name)
+		}
+
+		if ty, found := types[name]; found && ty != cty.NilType {
+			converted, err := convert.Convert(val, ty)
+			if err != nil {
+				return errors.Wrapf(err, "tfvar: output %s cannot be assigned to var.%s", traversalString(traversal), name)
+			}
+
+			val = converted
+		}
+
+		to[name] = unparsedVariableValueConst{val: val}
+	}
+
+	return nil
+}
+
+// traversalString returns the source code of traversal, e.g. network.subnets[0].
+func traversalString(traversal hcl.Traversal) string {
+	var b strings.Builder
+
+	for _, step := range traversal {
+		switch s := step.(type) {
+		case hcl.TraverseRoot:
+			b.WriteString(s.Name)
+		case hcl.TraverseAttr:
+			b.WriteString("." + s.Name)
+		case hcl.TraverseIndex:
+			b.WriteString("[" + string(hclwrite.TokensForValue(s.Key).Bytes()) + "]")
+		}
+	}
+
+	return b.String()
+}
+
+// unparsedVariableValueConst is a value that is already known, e.g. an output.
+type unparsedVariableValueConst struct {
+	val cty.Value
+}
+
+func (v unparsedVariableValueConst) ParseVariableValue(_ configs.VariableParsingMode) (cty.Value, error) {
+	return v.val, nil
+}
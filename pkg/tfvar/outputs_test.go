@@ -0,0 +1,153 @@
+package tfvar
+
+import (
+	"testing"
+
+	"github.com/shihanng/tfvar/pkg/configs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestReadOutputsFile(t *testing.T) {
+	subnetIDs := cty.TupleVal([]cty.Value{cty.StringVal("subnet-1"), cty.StringVal("subnet-2")})
+
+	outputs, err := ReadOutputsFile("testdata/outputs/output.json")
+	require.NoError(t, err)
+	assert.Len(t, outputs, 3)
+	assert.True(t, subnetIDs.RawEquals(outputs["subnet_ids"]))
+	assert.Equal(t, cty.StringVal("vpc-123"), outputs["network"].GetAttr("vpc_id"))
+
+	outputs, err = ReadOutputsFile("testdata/outputs/terraform.tfstate")
+	require.NoError(t, err)
+	assert.Len(t, outputs, 1)
+	assert.True(t, subnetIDs.RawEquals(outputs["subnet_ids"]))
+
+	_, err = ReadOutputsFile("testdata/plan.json")
+	assert.Error(t, err)
+}
+
+func TestParseOutputsNamedVersion(t *testing.T) {
+	outputs, err := ParseOutputs([]byte(`{
+  "version": {"type": "string", "value": "1.2.3"},
+  "outputs": {"type": "string", "value": "all"}
+}`), "output.json")
+	require.NoError(t, err)
+	assert.Equal(t, Outputs{
+		"version": cty.StringVal("1.2.3"),
+		"outputs": cty.StringVal("all"),
+	}, outputs)
+}
+
+func TestParseOutputMapping(t *testing.T) {
+	mapping, err := ReadOutputMappingFile("testdata/outputs/mapping.txt")
+	require.NoError(t, err)
+	assert.Len(t, mapping, 3)
+	assert.Equal(t, "network.azs[0]", traversalString(mapping["first_az"]))
+
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "no arrow",
+			src:  "vpc_id = network.vpc_id\n",
+			want: "mapping.txt:1,1-24: Invalid output mapping",
+		},
+		{
+			name: "bad variable name",
+			src:  "\n1vpc <- network.vpc_id\n",
+			want: "mapping.txt:2,1-6: Invalid variable name",
+		},
+		{
+			name: "bad traversal",
+			src:  "vpc_id <- network.\n",
+			want: "mapping.txt:1,19-19: Attribute name required",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseOutputMapping([]byte(tt.src), "mapping.txt")
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestCollectFromOutputs(t *testing.T) {
+	outputs, err := ReadOutputsFile("testdata/outputs/output.json")
+	require.NoError(t, err)
+
+	mapping, err := ReadOutputMappingFile("testdata/outputs/mapping.txt")
+	require.NoError(t, err)
+
+	vars := []Variable{
+		{Name: "first_az", Type: cty.String},
+		{Name: "instances", Type: cty.Number},
+		{Name: "subnet_ids", Type: cty.List(cty.String)},
+		{Name: "vpc_id", Type: cty.DynamicPseudoType},
+	}
+
+	tests := []struct {
+		name      string
+		mapping   OutputMapping
+		vars      []Variable
+		want      map[string]cty.Value
+		assertion assert.ErrorAssertionFunc
+	}{
+		{
+			name: "same names",
+			vars: vars,
+			want: map[string]cty.Value{
+				"instances":  cty.NumberIntVal(3),
+				"subnet_ids": cty.ListVal([]cty.Value{cty.StringVal("subnet-1"), cty.StringVal("subnet-2")}),
+			},
+			assertion: assert.NoError,
+		},
+		{
+			name:    "mapping",
+			mapping: mapping,
+			vars:    vars,
+			want: map[string]cty.Value{
+				"first_az":   cty.StringVal("ap-northeast-1a"),
+				"subnet_ids": cty.ListVal([]cty.Value{cty.StringVal("subnet-1"), cty.StringVal("subnet-2")}),
+				"vpc_id":     cty.StringVal("vpc-123"),
+			},
+			assertion: assert.NoError,
+		},
+		{
+			name:      "unknown output",
+			mapping:   OutputMapping{"vpc_id": mapping["vpc_id"], "first_az": mapping["first_az"][:1]},
+			vars:      vars,
+			assertion: assert.Error,
+		},
+		{
+			name:      "type mismatch",
+			vars:      []Variable{{Name: "subnet_ids", Type: cty.Number}},
+			assertion: assert.Error,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			actual := make(map[string]UnparsedVariableValue)
+			tt.assertion(t, CollectFromOutputs(outputs, tt.mapping, tt.vars, actual))
+
+			if tt.want == nil {
+				return
+			}
+
+			assert.Len(t, actual, len(tt.want))
+
+			for name, want := range tt.want {
+				val, err := actual[name].ParseVariableValue(configs.VariableParseLiteral)
+				require.NoError(t, err)
+				assert.True(t, want.RawEquals(val), "%s: want %#v, got %#v", name, want, val)
+			}
+		})
+	}
+}
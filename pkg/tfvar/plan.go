@@ -0,0 +1,78 @@
+package tfvar
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+
+	"github.com/cockroachdb/errors"
+	"github.com/shihanng/tfvar/pkg/configs"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// planJSON is the part of the JSON representation of a saved plan, as output by
+// terraform show -json plan.out, that holds the values of the input variables.
+type planJSON struct {
+	FormatVersion string `json:"format_version"`
+	Variables     map[string]struct {
+		Value json.RawMessage `json:"value"`
+	} `json:"variables"`
+}
+
+// CollectFromPlanFile extracts the values of the variables a plan was made with from filename,
+// the JSON representation of the saved plan, e.g.
+//    $ terraform plan -out plan.out
+//    $ terraform show -json plan.out > plan.json
+func CollectFromPlanFile(filename string, to map[string]UnparsedVariableValue) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return errors.Wrapf(err, "tfvar: reading file '%s'", filename)
+	}
+	defer f.Close()
+
+	return CollectFromPlanJSON(f, filename, to)
+}
+
+// CollectFromPlanJSON is like CollectFromPlanFile but reads the JSON representation of the plan from r.
+// The name is used in errors.
+func CollectFromPlanJSON(r io.Reader, name string, to map[string]UnparsedVariableValue) error {
+	var plan planJSON
+	if err := json.NewDecoder(r).Decode(&plan); err != nil {
+		return errors.Wrapf(err, "tfvar: failed to parse plan '%s'", name)
+	}
+
+	if plan.FormatVersion == "" {
+		return errors.Errorf("tfvar: '%s' is not a plan in JSON, see terraform show -json", name)
+	}
+
+	for varName, v := range plan.Variables {
+		to[varName] = unparsedVariableValueJSON{
+			raw:  v.Value,
+			name: varName,
+		}
+	}
+
+	return nil
+}
+
+// unparsedVariableValueJSON is a value in JSON whose type is implied from the JSON value itself,
+// since the JSON representation of a plan does not record the types of the variables.
+type unparsedVariableValueJSON struct {
+	raw  json.RawMessage
+	name string
+}
+
+func (v unparsedVariableValueJSON) ParseVariableValue(_ configs.VariableParsingMode) (cty.Value, error) {
+	ty, err := ctyjson.ImpliedType(v.raw)
+	if err != nil {
+		return cty.Value{}, errors.Wrapf(err, "tfvar: failed to parse value of var.%s", v.name)
+	}
+
+	val, err := ctyjson.Unmarshal(v.raw, ty)
+	if err != nil {
+		return cty.Value{}, errors.Wrapf(err, "tfvar: failed to parse value of var.%s", v.name)
+	}
+
+	return val, nil
+}
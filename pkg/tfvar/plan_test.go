@@ -0,0 +1,62 @@
+package tfvar
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/shihanng/tfvar/pkg/configs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestCollectFromPlanFile(t *testing.T) {
+	actual := make(map[string]UnparsedVariableValue)
+	require.NoError(t, CollectFromPlanFile("testdata/plan.json", actual))
+	assert.Len(t, actual, 3)
+
+	expected := map[string]cty.Value{
+		"image_id":  cty.StringVal("ami-abc123"),
+		"instances": cty.NumberIntVal(3),
+		"tags":      cty.ObjectVal(map[string]cty.Value{"env": cty.StringVal("prod")}),
+	}
+
+	for name, want := range expected {
+		val, err := actual[name].ParseVariableValue(configs.VariableParseLiteral)
+		require.NoError(t, err)
+		assert.True(t, want.RawEquals(val), "%s: want %#v, got %#v", name, want, val)
+	}
+
+	assert.Error(t, CollectFromPlanFile("testdata/unknown.json", actual))
+}
+
+func TestCollectFromPlanJSON(t *testing.T) {
+	tests := []struct {
+		name      string
+		src       string
+		assertion assert.ErrorAssertionFunc
+	}{
+		{
+			name:      "no variables",
+			src:       `{"format_version": "1.2"}`,
+			assertion: assert.NoError,
+		},
+		{
+			name:      "not a plan",
+			src:       `{"variables": {}}`,
+			assertion: assert.Error,
+		},
+		{
+			name:      "bad json",
+			src:       `{"format_version": `,
+			assertion: assert.Error,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tt.assertion(t, CollectFromPlanJSON(strings.NewReader(tt.src), "plan.json", map[string]UnparsedVariableValue{}))
+		})
+	}
+}
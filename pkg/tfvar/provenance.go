@@ -0,0 +1,36 @@
+package tfvar
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+// FormatProvenance is the name of the format that shows where the declarations of the variables
+// were set, see WriteAsProvenance.
+const FormatProvenance = "provenance"
+
+// WriteAsProvenance outputs, for each of the given vars, the files and source ranges of the attributes
+// of its declaration in the order they were merged, so that the ones changed by override files
+// (_override.tf) can be told apart, e.g.
+//    image_id
+//      type at variables.tf:2,3-16
+//      default at variables.tf:3,3-20
+//      default at override.tf:2,3-22 (override)
+func WriteAsProvenance(w io.Writer, vars []Variable) error {
+	var b strings.Builder
+
+	for _, v := range vars {
+		b.WriteString(v.Name)
+		b.WriteString("\n")
+
+		for _, attr := range v.History {
+			fmt.Fprintf(&b, "  %s\n", attr)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return errors.Wrap(err, "tfvar: failed to write as provenance")
+}
@@ -0,0 +1,28 @@
+package tfvar
+
+import (
+	"bytes"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestWriteAsProvenance(t *testing.T) {
+	vars, err := Load("testdata/override")
+	require.NoError(t, err)
+
+	sort.Slice(vars, func(i, j int) bool { return vars[i].Name < vars[j].Name })
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteAsProvenance(&buf, vars))
+	assert.Equal(t, `image_id
+  type at testdata/override/variables.tf:2,3-19
+  default at testdata/override/variables.tf:3,3-25
+  default at testdata/override/variables_override.tf:2,3-25 (override)
+region
+`, buf.String())
+	assert.Equal(t, cty.StringVal("ami-def456"), vars[0].Value)
+}
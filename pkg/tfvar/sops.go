@@ -0,0 +1,562 @@
+package tfvar
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"github.com/cockroachdb/errors"
+	"github.com/zclconf/go-cty/cty"
+	"gopkg.in/yaml.v3"
+)
+
+// Environment variables SOPS reads the age keys from, see https://github.com/getsops/sops#encrypting-using-age.
+const (
+	sopsAgeKeyEnv     = "SOPS_AGE_KEY"
+	sopsAgeKeyFileEnv = "SOPS_AGE_KEY_FILE"
+)
+
+// sopsMetadataKey is the key of the metadata SOPS adds to the files it encrypts. In dotenv files,
+// the metadata is flattened into keys with this prefix, e.g. sops_mac.
+const sopsMetadataKey = "sops"
+
+var sopsEncrypted = regexp.MustCompile(`^ENC\[AES256_GCM,data:(.*),iv:(.+),tag:(.+),type:(.+)\]$`)
+
+// sopsMetadata is the part of the SOPS metadata needed to decrypt a file with age keys.
+type sopsMetadata struct {
+	Age              []sopsAgeKey `yaml:"age"`
+	LastModified     string       `yaml:"lastmodified"`
+	MAC              string       `yaml:"mac"`
+	MACOnlyEncrypted bool         `yaml:"mac_only_encrypted"`
+}
+
+// sopsAgeKey is the data key of a SOPS file encrypted for an age recipient.
+type sopsAgeKey struct {
+	Recipient string `yaml:"recipient"`
+	Enc       string `yaml:"enc"`
+}
+
+// IsSOPSFile reports whether src, the content of filename, is encrypted with SOPS, i.e. it has the SOPS
+// metadata with a MAC and a modification time. JSON, YAML (.yaml, .yml), and dotenv (.env) files are supported.
+// A var file with a variable named sops is not encrypted.
+func IsSOPSFile(src []byte, filename string) bool {
+	switch {
+	case isDotenvFile(filename):
+		var hasMAC, hasLastModified bool
+
+		for _, line := range strings.Split(string(src), "\n") {
+			hasMAC = hasMAC || strings.HasPrefix(line, sopsMetadataKey+"_mac=")
+			hasLastModified = hasLastModified || strings.HasPrefix(line, sopsMetadataKey+"_lastmodified=")
+		}
+
+		return hasMAC && hasLastModified
+	case strings.HasSuffix(filename, ".json") || isYAMLFile(filename):
+		// Most var files are not encrypted, so they are not parsed unless they may have the metadata.
+		if !bytes.Contains(src, []byte(sopsMetadataKey)) {
+			return false
+		}
+
+		root, err := parseYAML(src)
+		return err == nil && sopsMetadataNode(root) != nil
+	default:
+		return false
+	}
+}
+
+func isYAMLFile(filename string) bool {
+	return strings.HasSuffix(filename, ".yaml") || strings.HasSuffix(filename, ".yml")
+}
+
+func isDotenvFile(filename string) bool {
+	return strings.HasSuffix(filename, ".env")
+}
+
+// collectFromSOPS is like collectFromSource for src encrypted with SOPS. It is decrypted in memory with
+// the age keys of SOPS_AGE_KEY, SOPS_AGE_KEY_FILE, or SOPS's default keys file, looked up in environ.
+func collectFromSOPS(src []byte, filename string, environ []string, to map[string]UnparsedVariableValue) error {
+	if isDotenvFile(filename) {
+		return collectFromSOPSDotenv(src, filename, environ, to)
+	}
+
+	root, err := parseYAML(src)
+	if err != nil {
+		return errors.Wrapf(err, "tfvar: failed to parse '%s'", filename)
+	}
+
+	metaNode := sopsMetadataNode(root)
+	if metaNode == nil {
+		return errors.Errorf("tfvar: '%s' is not encrypted with SOPS", filename)
+	}
+
+	var meta sopsMetadata
+	if err := metaNode.Decode(&meta); err != nil {
+		return errors.Wrapf(err, "tfvar: failed to parse SOPS metadata of '%s'", filename)
+	}
+
+	d, err := newSOPSDecrypter(meta, filename, environ)
+	if err != nil {
+		return err
+	}
+
+	values := make(map[string]cty.Value)
+
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		k, v := root.Content[i], root.Content[i+1]
+		if k.Value == sopsMetadataKey {
+			continue
+		}
+
+		val, err := d.yamlValue(v, []string{k.Value})
+		if err != nil {
+			return err
+		}
+
+		values[k.Value] = val
+	}
+
+	if err := d.verify(); err != nil {
+		return err
+	}
+
+	for name, val := range values {
+		to[name] = unparsedVariableValueConst{val: val}
+	}
+
+	return nil
+}
+
+// parseYAML returns the top-level mapping of src, which is either YAML or JSON.
+func parseYAML(src []byte) (*yaml.Node, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(src, &doc); err != nil {
+		return nil, err
+	}
+
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) != 1 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, errors.New("the root value must be an object")
+	}
+
+	return doc.Content[0], nil
+}
+
+// sopsMetadataNode returns the SOPS metadata of root, or nil if root has no sops mapping with
+// a MAC and a modification time, e.g. it is a variable named sops.
+func sopsMetadataNode(root *yaml.Node) *yaml.Node {
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		node := root.Content[i+1]
+		if root.Content[i].Value != sopsMetadataKey || node.Kind != yaml.MappingNode {
+			continue
+		}
+
+		var hasMAC, hasLastModified bool
+
+		for j := 0; j+1 < len(node.Content); j += 2 {
+			if node.Content[j+1].Kind != yaml.ScalarNode {
+				continue
+			}
+
+			switch node.Content[j].Value {
+			case "mac":
+				hasMAC = true
+			case "lastmodified":
+				hasLastModified = true
+			}
+		}
+
+		if hasMAC && hasLastModified {
+			return node
+		}
+	}
+
+	return nil
+}
+
+// collectFromSOPSDotenv is collectFromSOPS for dotenv files, whose values are parsed as the ones of
+// TF_VAR_* environment variables.
+func collectFromSOPSDotenv(src []byte, filename string, environ []string, to map[string]UnparsedVariableValue) error {
+	type item struct {
+		key, value string
+	}
+
+	var (
+		items []item
+		meta  sopsMetadata
+	)
+
+	for _, line := range strings.Split(string(src), "\n") {
+		line = strings.TrimRight(line, "\r")
+
+		// The comments, which SOPS encrypts too, are not part of the MAC.
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		eq := strings.Index(line, "=")
+		if eq < 0 {
+			return errors.Errorf("tfvar: invalid line in '%s': %s", filename, line)
+		}
+
+		key, value := line[:eq], line[eq+1:]
+
+		if !strings.HasPrefix(key, sopsMetadataKey+"_") {
+			items = append(items, item{key: key, value: value})
+			continue
+		}
+
+		// The metadata is flattened, e.g. sops_age__list_0__map_enc, with newlines escaped.
+		value = strings.ReplaceAll(value, `\n`, "\n")
+
+		switch field := strings.TrimPrefix(key, sopsMetadataKey+"_"); {
+		case field == "lastmodified":
+			meta.LastModified = value
+		case field == "mac":
+			meta.MAC = value
+		case field == "mac_only_encrypted":
+			meta.MACOnlyEncrypted = value == "true"
+		case strings.HasPrefix(field, "age__list_"):
+			var (
+				i    int
+				attr string
+			)
+
+			if _, err := fmt.Sscanf(field, "age__list_%d__map_%s", &i, &attr); err != nil || i < 0 || i > 1000 {
+				continue
+			}
+
+			for len(meta.Age) <= i {
+				meta.Age = append(meta.Age, sopsAgeKey{})
+			}
+
+			switch attr {
+			case "enc":
+				meta.Age[i].Enc = value
+			case "recipient":
+				meta.Age[i].Recipient = value
+			}
+		}
+	}
+
+	if meta.MAC == "" {
+		return errors.Errorf("tfvar: '%s' is not encrypted with SOPS", filename)
+	}
+
+	d, err := newSOPSDecrypter(meta, filename, environ)
+	if err != nil {
+		return err
+	}
+
+	values := make(map[string]string)
+
+	for _, it := range items {
+		val, err := d.decrypt(it.value, []string{it.key})
+		if err != nil {
+			return err
+		}
+
+		s, ok := val.(string)
+		if !ok {
+			s = string(sopsBytes(val))
+		}
+
+		values[it.key] = s
+	}
+
+	if err := d.verify(); err != nil {
+		return err
+	}
+
+	for name, s := range values {
+		to[name] = unparsedVariableValueString{
+			str:  s,
+			name: name,
+		}
+	}
+
+	return nil
+}
+
+// sopsDecrypter decrypts the values of a file encrypted with SOPS and computes their MAC.
+type sopsDecrypter struct {
+	filename string
+	meta     sopsMetadata
+	key      []byte
+	mac      hash.Hash
+}
+
+func newSOPSDecrypter(meta sopsMetadata, filename string, environ []string) (*sopsDecrypter, error) {
+	if len(meta.Age) == 0 {
+		return nil, errors.Errorf("tfvar: '%s' is not encrypted with an age key, only age keys are supported", filename)
+	}
+
+	identities, err := sopsAgeIdentities(environ)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(identities) == 0 {
+		return nil, errors.Errorf("tfvar: no age key to decrypt '%s', set %s or %s", filename, sopsAgeKeyFileEnv, sopsAgeKeyEnv)
+	}
+
+	var lastErr error
+
+	for _, a := range meta.Age {
+		r, err := age.Decrypt(armor.NewReader(strings.NewReader(a.Enc)), identities...)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		key, err := ioutil.ReadAll(r)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		return &sopsDecrypter{
+			filename: filename,
+			meta:     meta,
+			key:      key,
+			mac:      sha512.New(),
+		}, nil
+	}
+
+	return nil, errors.Wrapf(lastErr, "tfvar: failed to decrypt the data key of '%s' with the age keys", filename)
+}
+
+// sopsAgeIdentities returns the age keys of SOPS_AGE_KEY and SOPS_AGE_KEY_FILE in environ, or of the default
+// keys file of SOPS, $XDG_CONFIG_HOME/sops/age/keys.txt, if SOPS_AGE_KEY_FILE is not set.
+func sopsAgeIdentities(environ []string) ([]age.Identity, error) {
+	var identities []age.Identity
+
+	if key := lookupEnviron(environ, sopsAgeKeyEnv); key != "" {
+		ids, err := age.ParseIdentities(strings.NewReader(key))
+		if err != nil {
+			return nil, errors.Wrapf(err, "tfvar: failed to parse the age keys of %s", sopsAgeKeyEnv)
+		}
+
+		identities = append(identities, ids...)
+	}
+
+	filename, isSet := lookupEnvironOK(environ, sopsAgeKeyFileEnv)
+	if !isSet {
+		dir := lookupEnviron(environ, "XDG_CONFIG_HOME")
+		if dir == "" {
+			var err error
+			if dir, err = os.UserConfigDir(); err != nil {
+				return identities, nil
+			}
+		}
+
+		filename = filepath.Join(dir, "sops", "age", "keys.txt")
+	}
+
+	f, err := os.Open(filename)
+	switch {
+	case os.IsNotExist(err) && !isSet:
+		return identities, nil
+	case err != nil:
+		return nil, errors.Wrapf(err, "tfvar: reading age keys file '%s'", filename)
+	}
+	defer f.Close()
+
+	ids, err := age.ParseIdentities(f)
+	if err != nil {
+		return nil, errors.Wrapf(err, "tfvar: failed to parse the age keys of '%s'", filename)
+	}
+
+	return append(identities, ids...), nil
+}
+
+// decrypt returns the plaintext of value at path, or value itself if it is not encrypted,
+// and adds it to the MAC.
+func (d *sopsDecrypter) decrypt(value interface{}, path []string) (interface{}, error) {
+	s, isString := value.(string)
+	if !isString || !sopsEncrypted.MatchString(s) {
+		if !d.meta.MACOnlyEncrypted && value != nil {
+			d.mac.Write(sopsBytes(value))
+		}
+
+		return value, nil
+	}
+
+	plain, err := d.decryptString(s, strings.Join(path, ":")+":")
+	if err != nil {
+		return nil, errors.Wrapf(err, "tfvar: failed to decrypt %s of '%s'", strings.Join(path, "."), d.filename)
+	}
+
+	d.mac.Write(sopsBytes(plain))
+
+	return plain, nil
+}
+
+func (d *sopsDecrypter) decryptString(s, additionalData string) (interface{}, error) {
+	m := sopsEncrypted.FindStringSubmatch(s)
+	if m == nil {
+		return nil, errors.New("not an encrypted value")
+	}
+
+	var parts [3][]byte
+
+	for i, encoded := range m[1:4] {
+		b, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, err
+		}
+
+		parts[i] = b
+	}
+
+	data, iv, tag := parts[0], parts[1], parts[2]
+
+	block, err := aes.NewCipher(d.key)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := cipher.NewGCMWithNonceSize(block, len(iv))
+	if err != nil {
+		return nil, err
+	}
+
+	plain, err := gcm.Open(nil, iv, append(data, tag...), []byte(additionalData))
+	if err != nil {
+		return nil, err
+	}
+
+	switch typ := m[4]; typ {
+	case "str", "comment":
+		return string(plain), nil
+	case "bytes":
+		return plain, nil
+	case "int":
+		return strconv.Atoi(string(plain))
+	case "float":
+		return strconv.ParseFloat(string(plain), 64)
+	case "bool":
+		return strconv.ParseBool(string(plain))
+	default:
+		return nil, errors.Errorf("unknown type %s", typ)
+	}
+}
+
+// verify checks the MAC of the decrypted values against the one of the metadata,
+// so that tampered values are not used.
+func (d *sopsDecrypter) verify() error {
+	mac, err := d.decryptString(d.meta.MAC, d.meta.LastModified)
+	if err != nil {
+		return errors.Wrapf(err, "tfvar: failed to decrypt the MAC of '%s'", d.filename)
+	}
+
+	if mac != fmt.Sprintf("%X", d.mac.Sum(nil)) {
+		return errors.Errorf("tfvar: MAC mismatch in '%s', the file may have been tampered with", d.filename)
+	}
+
+	return nil
+}
+
+// sopsBytes returns the representation of value SOPS computes the MAC with.
+func sopsBytes(value interface{}) []byte {
+	switch v := value.(type) {
+	case string:
+		return []byte(v)
+	case []byte:
+		return v
+	case int:
+		return []byte(strconv.Itoa(v))
+	case float64:
+		return []byte(strconv.FormatFloat(v, 'f', -1, 64))
+	case bool:
+		if v {
+			return []byte("True")
+		}
+
+		return []byte("False")
+	default:
+		return []byte(fmt.Sprint(v))
+	}
+}
+
+// yamlValue decrypts node at path and returns it as a cty.Value.
+func (d *sopsDecrypter) yamlValue(node *yaml.Node, path []string) (cty.Value, error) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		attrs := make(map[string]cty.Value, len(node.Content)/2)
+
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			k, v := node.Content[i], node.Content[i+1]
+
+			val, err := d.yamlValue(v, append(path[:len(path):len(path)], k.Value))
+			if err != nil {
+				return cty.NilVal, err
+			}
+
+			attrs[k.Value] = val
+		}
+
+		return cty.ObjectVal(attrs), nil
+	case yaml.SequenceNode:
+		elems := make([]cty.Value, 0, len(node.Content))
+
+		for _, n := range node.Content {
+			val, err := d.yamlValue(n, path)
+			if err != nil {
+				return cty.NilVal, err
+			}
+
+			elems = append(elems, val)
+		}
+
+		if len(elems) == 0 {
+			return cty.EmptyTupleVal, nil
+		}
+
+		return cty.TupleVal(elems), nil
+	case yaml.ScalarNode:
+		var value interface{}
+		if err := node.Decode(&value); err != nil {
+			return cty.NilVal, errors.Wrapf(err, "tfvar: failed to parse %s of '%s'", strings.Join(path, "."), d.filename)
+		}
+
+		plain, err := d.decrypt(value, path)
+		if err != nil {
+			return cty.NilVal, err
+		}
+
+		return sopsCtyValue(plain), nil
+	case yaml.AliasNode:
+		return d.yamlValue(node.Alias, path)
+	default:
+		return cty.NilVal, errors.Errorf("tfvar: unsupported value at %s of '%s'", strings.Join(path, "."), d.filename)
+	}
+}
+
+func sopsCtyValue(value interface{}) cty.Value {
+	switch v := value.(type) {
+	case nil:
+		return cty.NullVal(cty.DynamicPseudoType)
+	case string:
+		return cty.StringVal(v)
+	case []byte:
+		return cty.StringVal(string(v))
+	case int:
+		return cty.NumberIntVal(int64(v))
+	case float64:
+		return cty.NumberFloatVal(v)
+	case bool:
+		return cty.BoolVal(v)
+	default:
+		return cty.StringVal(fmt.Sprint(v))
+	}
+}
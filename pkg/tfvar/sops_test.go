@@ -0,0 +1,351 @@
+package tfvar
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"github.com/shihanng/tfvar/pkg/configs"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+const sopsTestLastModified = "2024-05-01T10:00:00Z"
+
+// sopsTestFile encrypts values the way SOPS does, with a data key encrypted for the age identity.
+type sopsTestFile struct {
+	t        *testing.T
+	key      []byte
+	identity *age.X25519Identity
+	mac      [][]byte
+}
+
+func newSOPSTestFile(t *testing.T) *sopsTestFile {
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	key := make([]byte, 32)
+	_, err = rand.Read(key)
+	require.NoError(t, err)
+
+	return &sopsTestFile{t: t, key: key, identity: identity}
+}
+
+func (f *sopsTestFile) encrypt(value interface{}, additionalData string) string {
+	typ, plain := "str", []byte(fmt.Sprint(value))
+
+	switch v := value.(type) {
+	case int:
+		typ = "int"
+	case bool:
+		typ, plain = "bool", []byte(strings.Title(strconv.FormatBool(v)))
+	}
+
+	f.mac = append(f.mac, plain)
+
+	return f.seal(plain, typ, additionalData)
+}
+
+// encryptComment encrypts a comment, which SOPS does not add to the MAC.
+func (f *sopsTestFile) encryptComment(text, additionalData string) string {
+	return f.seal([]byte(text), "comment", additionalData)
+}
+
+func (f *sopsTestFile) seal(plain []byte, typ, additionalData string) string {
+	iv := make([]byte, 32)
+	_, err := rand.Read(iv)
+	require.NoError(f.t, err)
+
+	block, err := aes.NewCipher(f.key)
+	require.NoError(f.t, err)
+
+	gcm, err := cipher.NewGCMWithNonceSize(block, len(iv))
+	require.NoError(f.t, err)
+
+	out := gcm.Seal(nil, iv, plain, []byte(additionalData))
+	data, tag := out[:len(out)-gcm.Overhead()], out[len(out)-gcm.Overhead():]
+
+	return fmt.Sprintf("ENC[AES256_GCM,data:%s,iv:%s,tag:%s,type:%s]",
+		base64.StdEncoding.EncodeToString(data),
+		base64.StdEncoding.EncodeToString(iv),
+		base64.StdEncoding.EncodeToString(tag),
+		typ)
+}
+
+// encryptedKey returns the armored data key encrypted for the age identity.
+func (f *sopsTestFile) encryptedKey() string {
+	var buf bytes.Buffer
+
+	aw := armor.NewWriter(&buf)
+	w, err := age.Encrypt(aw, f.identity.Recipient())
+	require.NoError(f.t, err)
+	_, err = w.Write(f.key)
+	require.NoError(f.t, err)
+	require.NoError(f.t, w.Close())
+	require.NoError(f.t, aw.Close())
+
+	return buf.String()
+}
+
+// encryptedMAC returns the MAC of the values encrypted so far.
+func (f *sopsTestFile) encryptedMAC() string {
+	h := sha512.New()
+	for _, b := range f.mac {
+		h.Write(b)
+	}
+
+	return f.seal([]byte(fmt.Sprintf("%X", h.Sum(nil))), "str", sopsTestLastModified)
+}
+
+// writeKeys writes the age identity to a keys file and returns the environment pointing SOPS_AGE_KEY_FILE to it.
+func (f *sopsTestFile) writeKeys(dir string) []string {
+	keys := filepath.Join(dir, "keys.txt")
+	require.NoError(f.t, ioutil.WriteFile(keys, []byte(f.identity.String()+"\n"), 0600))
+
+	return []string{sopsAgeKeyFileEnv + "=" + keys}
+}
+
+func TestCollectFromFileSOPS(t *testing.T) {
+	dir := t.TempDir()
+	f := newSOPSTestFile(t)
+
+	region := f.encrypt("ap-northeast-1", "region:")
+	instances := f.encrypt(3, "instances:")
+	env := f.encrypt("prod", "tags:env:")
+	zoneA := f.encrypt("ap-northeast-1a", "zones:")
+	zoneC := f.encrypt("ap-northeast-1c", "zones:")
+	enabled := f.encrypt(true, "enabled:")
+	mac := f.encryptedMAC()
+
+	jsonSrc := fmt.Sprintf(`{
+	"region": %q,
+	"instances": %q,
+	"tags": {"env": %q},
+	"zones": [%q, %q],
+	"enabled": %q,
+	"sops": {
+		"age": [{"recipient": %q, "enc": %q}],
+		"lastmodified": %q,
+		"mac": %q,
+		"version": "3.8.1"
+	}
+}`, region, instances, env, zoneA, zoneC, enabled, f.identity.Recipient(), f.encryptedKey(), sopsTestLastModified, mac)
+
+	yamlSrc := fmt.Sprintf(`#%s
+region: %s
+instances: %s
+tags:
+    env: %s
+zones:
+    - %s
+    - %s
+enabled: %s
+sops:
+    age:
+        - recipient: %s
+          enc: |
+%s
+    lastmodified: "%s"
+    mac: %s
+    version: 3.8.1
+`, f.encryptComment(" region of the deployment", ":"), region, instances, env, zoneA, zoneC, enabled, f.identity.Recipient(),
+		indent(f.encryptedKey(), "            "), sopsTestLastModified, mac)
+
+	files := map[string]string{
+		"secrets.json": jsonSrc,
+		"secrets.yaml": yamlSrc,
+		"tampered.json": strings.Replace(jsonSrc,
+			fmt.Sprintf(`"zones": [%q, %q]`, zoneA, zoneC), fmt.Sprintf(`"zones": [%q, %q]`, zoneC, zoneA), 1),
+		"moved.json": strings.Replace(jsonSrc, `"region"`, `"location"`, 1),
+	}
+
+	for name, src := range files {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(src), 0600))
+	}
+
+	environ := f.writeKeys(dir)
+
+	want := map[string]cty.Value{
+		"region":    cty.StringVal("ap-northeast-1"),
+		"instances": cty.NumberIntVal(3),
+		"tags":      cty.ObjectVal(map[string]cty.Value{"env": cty.StringVal("prod")}),
+		"zones":     cty.TupleVal([]cty.Value{cty.StringVal("ap-northeast-1a"), cty.StringVal("ap-northeast-1c")}),
+		"enabled":   cty.True,
+	}
+
+	for _, name := range []string{"secrets.json", "secrets.yaml"} {
+		t.Run(name, func(t *testing.T) {
+			actual := make(map[string]UnparsedVariableValue)
+			require.NoError(t, collectFromFileEnviron(nil, filepath.Join(dir, name), environ, actual))
+			assert.Len(t, actual, len(want))
+
+			for varName, w := range want {
+				val, err := actual[varName].ParseVariableValue(configs.VariableParseLiteral)
+				require.NoError(t, err)
+				assert.True(t, w.RawEquals(val), "%s: want %#v, got %#v", varName, w, val)
+			}
+		})
+	}
+
+	errs := map[string]string{
+		"tampered.json": "MAC mismatch",
+		"moved.json":    "failed to decrypt location",
+	}
+
+	for name, want := range errs {
+		t.Run(name, func(t *testing.T) {
+			err := collectFromFileEnviron(nil, filepath.Join(dir, name), environ, map[string]UnparsedVariableValue{})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), want)
+		})
+	}
+
+	t.Run("wrong key", func(t *testing.T) {
+		other := newSOPSTestFile(t)
+		otherEnviron := other.writeKeys(t.TempDir())
+
+		err := collectFromFileEnviron(nil, filepath.Join(dir, "secrets.json"), otherEnviron, map[string]UnparsedVariableValue{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to decrypt the data key")
+	})
+}
+
+func TestCollectFromFileSOPSDotenv(t *testing.T) {
+	dir := t.TempDir()
+	f := newSOPSTestFile(t)
+
+	comment := f.encryptComment(" zones of the deployment", ":")
+	zones := f.encrypt(`["ap-northeast-1a"]`, "zones:")
+	region := f.encrypt("ap-northeast-1", "region:")
+
+	src := fmt.Sprintf(`#%s
+zones=%s
+region=%s
+sops_age__list_0__map_enc=%s
+sops_age__list_0__map_recipient=%s
+sops_lastmodified=%s
+sops_mac=%s
+sops_version=3.8.1
+`, comment, zones, region, strings.ReplaceAll(f.encryptedKey(), "\n", `\n`), f.identity.Recipient(),
+		sopsTestLastModified, f.encryptedMAC())
+
+	filename := filepath.Join(dir, "secrets.env")
+	require.NoError(t, ioutil.WriteFile(filename, []byte(src), 0600))
+
+	environ := f.writeKeys(dir)
+
+	actual := make(map[string]UnparsedVariableValue)
+	require.NoError(t, collectFromFileEnviron(nil, filename, environ, actual))
+	assert.Len(t, actual, 2)
+
+	val, err := actual["zones"].ParseVariableValue(configs.VariableParseHCL)
+	require.NoError(t, err)
+	assert.Equal(t, cty.TupleVal([]cty.Value{cty.StringVal("ap-northeast-1a")}), val)
+
+	val, err = actual["region"].ParseVariableValue(configs.VariableParseLiteral)
+	require.NoError(t, err)
+	assert.Equal(t, cty.StringVal("ap-northeast-1"), val)
+}
+
+func TestCollectFromFileSOPSFixtures(t *testing.T) {
+	environ := []string{sopsAgeKeyFileEnv + "=testdata/sops/keys.txt"}
+
+	want := map[string]cty.Value{
+		"region":    cty.StringVal("ap-northeast-1"),
+		"instances": cty.NumberIntVal(3),
+		"tags":      cty.ObjectVal(map[string]cty.Value{"env": cty.StringVal("prod")}),
+		"zones":     cty.TupleVal([]cty.Value{cty.StringVal("ap-northeast-1a"), cty.StringVal("ap-northeast-1c")}),
+		"enabled":   cty.True,
+	}
+
+	for _, name := range []string{"secrets.json", "secrets.yaml"} {
+		t.Run(name, func(t *testing.T) {
+			actual := make(map[string]UnparsedVariableValue)
+			require.NoError(t, collectFromFileEnviron(nil, filepath.Join("testdata/sops", name), environ, actual))
+			assert.Len(t, actual, len(want))
+
+			for varName, w := range want {
+				val, err := actual[varName].ParseVariableValue(configs.VariableParseLiteral)
+				require.NoError(t, err)
+				assert.True(t, w.RawEquals(val), "%s: want %#v, got %#v", varName, w, val)
+			}
+		})
+	}
+
+	t.Run("secrets.env", func(t *testing.T) {
+		actual := make(map[string]UnparsedVariableValue)
+		require.NoError(t, collectFromFileEnviron(nil, "testdata/sops/secrets.env", environ, actual))
+
+		val, err := actual["zones"].ParseVariableValue(configs.VariableParseHCL)
+		require.NoError(t, err)
+		assert.Equal(t, cty.TupleVal([]cty.Value{cty.StringVal("ap-northeast-1a")}), val)
+	})
+
+	t.Run("missing keys file", func(t *testing.T) {
+		environ := []string{sopsAgeKeyFileEnv + "=" + filepath.Join(t.TempDir(), "keys.txt")}
+
+		err := collectFromFileEnviron(nil, "testdata/sops/secrets.json", environ, map[string]UnparsedVariableValue{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "reading age keys file")
+	})
+}
+
+func TestCollectFromFileVariableNamedSOPS(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/my.tfvars.json", []byte(`{"sops": {"mac": "x", "version": "3.8.1"}}`), 0644))
+
+	actual := make(map[string]UnparsedVariableValue)
+	require.NoError(t, CollectFromFileFS(fs, "/my.tfvars.json", actual))
+
+	val, err := actual["sops"].ParseVariableValue(configs.VariableParseLiteral)
+	require.NoError(t, err)
+	assert.Equal(t, cty.ObjectVal(map[string]cty.Value{
+		"mac":     cty.StringVal("x"),
+		"version": cty.StringVal("3.8.1"),
+	}), val)
+}
+
+func TestIsSOPSFile(t *testing.T) {
+	tests := []struct {
+		filename string
+		src      string
+		want     bool
+	}{
+		{filename: "a.json", src: `{"a": "x", "sops": {"mac": "x", "lastmodified": "y"}}`, want: true},
+		{filename: "a.json", src: `{"a": "x", "sops": {"mac": "x"}}`},
+		{filename: "a.json", src: `{"a": "x", "sops": {"mac": {}, "lastmodified": "y"}}`},
+		{filename: "a.json", src: `{"a": "x"}`},
+		{filename: "a.yml", src: "a: x\nsops:\n  mac: x\n  lastmodified: y\n", want: true},
+		{filename: "a.yml", src: "a: x\nsops:\n  mac: x\n"},
+		{filename: "a.env", src: "a=x\nsops_mac=x\nsops_lastmodified=y\n", want: true},
+		{filename: "a.env", src: "a=x\nsops_mac=x\n"},
+		{filename: "a.env", src: "a=x\n"},
+		{filename: "a.tfvars", src: `sops = { mac = "x" }`},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, IsSOPSFile([]byte(tt.src), tt.filename), "%s: %s", tt.filename, tt.src)
+	}
+}
+
+func indent(s, prefix string) string {
+	lines := strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+	for i, l := range lines {
+		lines[i] = prefix + l
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,118 @@
+package tfvar
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/cockroachdb/errors"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// SyncResult describes the changes Sync made to a variable definitions file.
+type SyncResult struct {
+	// Added contains the names of the declared variables that were missing from the file.
+	Added []string
+	// Unknown contains the names of the attributes that do not match any declared variable.
+	Unknown []string
+	// Removed is true when the Unknown attributes were removed from the file.
+	Removed bool
+	// Ephemeral contains the names of the ephemeral variables that were missing from the file
+	// but not added, since their values must not be stored.
+	Ephemeral []string
+	// StoredEphemeral contains the names of the ephemeral variables whose values are stored in the file.
+	// They are removed from the file when prune is true.
+	StoredEphemeral []string
+}
+
+// Sync updates the content of an existing variable definitions file (.tfvars) so that it
+// contains all the given vars, e.g.
+//    # my comment is kept
+//    region = "ap-northeast-1"
+//    image_id = null
+// Existing attributes, their values, ordering, and comments are kept; the result is
+// formatted in Terraform's canonical style. Variables that are missing from the file are
+// appended with their current values, except the ephemeral ones. Attributes that do not match
+// any of vars are reported in SyncResult.Unknown, and the ones of ephemeral variables in
+// SyncResult.StoredEphemeral. Both are removed from the file when prune is true.
+func Sync(src []byte, filename string, vars []Variable, prune bool) ([]byte, SyncResult, error) {
+	if len(src) > 0 && !bytes.HasSuffix(src, []byte("\n")) {
+		src = append(src, '\n')
+	}
+
+	f, diags := hclwrite.ParseConfig(src, filename, hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		return nil, SyncResult{}, errors.Wrapf(diags, "tfvar: failed to parse '%s'", filename)
+	}
+
+	var ephemeral []string
+
+	result := syncBody(f.Body(), vars, prune, func(v Variable) bool {
+		if v.Ephemeral {
+			ephemeral = append(ephemeral, v.Name)
+		}
+
+		return !v.Ephemeral
+	})
+	result.Ephemeral = ephemeral
+
+	attrs := f.Body().Attributes()
+
+	for _, v := range vars {
+		if _, found := attrs[v.Name]; !found || !v.Ephemeral {
+			continue
+		}
+
+		result.StoredEphemeral = append(result.StoredEphemeral, v.Name)
+
+		if prune {
+			f.Body().RemoveAttribute(v.Name)
+			result.Removed = true
+		}
+	}
+
+	return f.Bytes(), result, nil
+}
+
+// syncBody adds the vars selected by add that are missing from the attributes of body, and reports
+// or removes the attributes that do not match any of vars.
+func syncBody(body *hclwrite.Body, vars []Variable, prune bool, add func(Variable) bool) SyncResult {
+	var result SyncResult
+
+	attrs := body.Attributes()
+
+	declared := make(map[string]struct{}, len(vars))
+
+	for _, v := range vars {
+		declared[v.Name] = struct{}{}
+
+		if _, found := attrs[v.Name]; found {
+			continue
+		}
+
+		if !add(v) {
+			continue
+		}
+
+		body.SetAttributeValue(v.Name, v.Value)
+		result.Added = append(result.Added, v.Name)
+	}
+
+	for name := range attrs {
+		if _, found := declared[name]; !found {
+			result.Unknown = append(result.Unknown, name)
+		}
+	}
+
+	sort.Strings(result.Unknown)
+
+	if prune {
+		for _, name := range result.Unknown {
+			body.RemoveAttribute(name)
+		}
+
+		result.Removed = len(result.Unknown) > 0
+	}
+
+	return result
+}
@@ -0,0 +1,131 @@
+package tfvar
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestSync(t *testing.T) {
+	src, err := ioutil.ReadFile("testdata/sync.tfvars")
+	require.NoError(t, err)
+
+	vars := []Variable{
+		{Name: "image_id", Value: cty.NullVal(cty.String)},
+		{Name: "instance_name", Value: cty.StringVal("my-instance")},
+		{Name: "region", Value: cty.StringVal("us-west-1")},
+	}
+
+	type args struct {
+		src   []byte
+		prune bool
+	}
+
+	tests := []struct {
+		name       string
+		args       args
+		want       string
+		wantResult SyncResult
+		assertion  assert.ErrorAssertionFunc
+	}{
+		{
+			name: "keep unknown",
+			args: args{
+				src: src,
+			},
+			want: `# Region of the deployment
+region = "ap-northeast-1" # Tokyo
+
+# No longer used
+legacy        = true
+image_id      = null
+instance_name = "my-instance"
+`,
+			wantResult: SyncResult{
+				Added:   []string{"image_id", "instance_name"},
+				Unknown: []string{"legacy"},
+			},
+			assertion: assert.NoError,
+		},
+		{
+			name: "prune unknown",
+			args: args{
+				src:   src,
+				prune: true,
+			},
+			want: `# Region of the deployment
+region = "ap-northeast-1" # Tokyo
+
+image_id      = null
+instance_name = "my-instance"
+`,
+			wantResult: SyncResult{
+				Added:   []string{"image_id", "instance_name"},
+				Unknown: []string{"legacy"},
+				Removed: true,
+			},
+			assertion: assert.NoError,
+		},
+		{
+			name: "empty file",
+			args: args{
+				src: nil,
+			},
+			want: `image_id      = null
+instance_name = "my-instance"
+region        = "us-west-1"
+`,
+			wantResult: SyncResult{
+				Added: []string{"image_id", "instance_name", "region"},
+			},
+			assertion: assert.NoError,
+		},
+		{
+			name: "bad file",
+			args: args{
+				src: []byte(`image_id`),
+			},
+			want:      "",
+			assertion: assert.Error,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, result, err := Sync(tt.args.src, "sync.tfvars", vars, tt.args.prune)
+			tt.assertion(t, err)
+			assert.Equal(t, tt.want, string(got))
+			assert.Equal(t, tt.wantResult, result)
+		})
+	}
+}
+
+func TestSyncEphemeral(t *testing.T) {
+	vars := []Variable{
+		{Name: "region", Value: cty.StringVal("us-west-1")},
+		{Name: "token", Value: cty.NullVal(cty.String), Ephemeral: true},
+	}
+
+	got, result, err := Sync(nil, "terraform.tfvars", vars, false)
+	require.NoError(t, err)
+	assert.Equal(t, "region = \"us-west-1\"\n", string(got))
+	assert.Equal(t, SyncResult{
+		Added:     []string{"region"},
+		Ephemeral: []string{"token"},
+	}, result)
+
+	src := []byte("region = \"us-west-1\"\ntoken  = \"secret\"\n")
+
+	got, result, err = Sync(src, "terraform.tfvars", vars, false)
+	require.NoError(t, err)
+	assert.Equal(t, string(src), string(got))
+	assert.Equal(t, SyncResult{StoredEphemeral: []string{"token"}}, result)
+
+	got, result, err = Sync(src, "terraform.tfvars", vars, true)
+	require.NoError(t, err)
+	assert.Equal(t, "region = \"us-west-1\"\n", string(got))
+	assert.Equal(t, SyncResult{StoredEphemeral: []string{"token"}, Removed: true}, result)
+}
@@ -0,0 +1,89 @@
+package tfvar
+
+import (
+	"io"
+	"strings"
+	"text/template"
+
+	"github.com/cockroachdb/errors"
+	"github.com/hashicorp/hcl/v2/ext/typeexpr"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+const redacted = "(sensitive)"
+
+// TemplateFuncs returns the helper functions available to the templates created by NewTemplate:
+//    hcl        HCL one-liner of a value, e.g. { external = 8300, protocol = "tcp" }
+//    json       JSON encoding of a value, e.g. {"external":8300,"protocol":"tcp"}
+//    type       type constraint of a type, e.g. list(string)
+//    shellquote single-quoted string that is safe to use in shell, e.g. 'it'\''s'
+//    redact     "(sensitive)" when the variable is sensitive, otherwise the given string
+func TemplateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"hcl":        templateHCL,
+		"json":       templateJSON,
+		"type":       templateType,
+		"shellquote": templateShellQuote,
+		"redact":     templateRedact,
+	}
+}
+
+// NewTemplate parses text as a Go text/template that can be used with WriteWithTemplate.
+// The template is executed with the []Variable to be written, e.g.
+//    {{ range . }}{{ .Name }}: {{ type .Type }} = {{ redact . (hcl .Value) }}
+//    {{ end }}
+func NewTemplate(name, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Funcs(TemplateFuncs()).Parse(text)
+	if err != nil {
+		return nil, errors.Wrap(err, "tfvar: failed to parse template")
+	}
+
+	return tmpl, nil
+}
+
+// WriteWithTemplate outputs the given vars using tmpl created by NewTemplate.
+func WriteWithTemplate(w io.Writer, tmpl *template.Template, vars []Variable) error {
+	return errors.Wrap(tmpl.Execute(w, vars), "tfvar: failed to execute template")
+}
+
+func templateHCL(val cty.Value) string {
+	if val == cty.NilVal {
+		return "null"
+	}
+
+	return string(formatOneliner(val))
+}
+
+func templateJSON(val cty.Value) (string, error) {
+	if val == cty.NilVal {
+		return "null", nil
+	}
+
+	b, err := ctyjson.Marshal(val, val.Type())
+	if err != nil {
+		return "", errors.Wrap(err, "tfvar: failed to encode as JSON")
+	}
+
+	return string(b), nil
+}
+
+func templateType(ty cty.Type) string {
+	if ty == cty.NilType {
+		return "any"
+	}
+
+	return typeexpr.TypeString(ty)
+}
+
+func templateShellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func templateRedact(v Variable, s string) string {
+	if v.Sensitive {
+		return redacted
+	}
+
+	return s
+}
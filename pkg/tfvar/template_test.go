@@ -0,0 +1,47 @@
+package tfvar
+
+import (
+	"bytes"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTemplate(t *testing.T) {
+	_, err := NewTemplate("bad", "{{ .Name ")
+	assert.Error(t, err)
+
+	_, err = NewTemplate("unknown", "{{ unknown .Name }}")
+	assert.Error(t, err)
+}
+
+func TestWriteWithTemplate(t *testing.T) {
+	vars, err := Load("testdata/defaults")
+	require.NoError(t, err)
+
+	sort.Slice(vars, func(i, j int) bool { return vars[i].Name < vars[j].Name })
+
+	tmpl, err := NewTemplate("test", `{{ range . -}}
+{{ .Name }} ({{ type .Type }}): {{ redact . (hcl .Value) }} {{ json .Value }} {{ shellquote .Description }}
+{{ end -}}`)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	assert.NoError(t, WriteWithTemplate(&buf, tmpl, vars))
+
+	expected := `availability_zone_names (list(string)): ["us-west-1a"] ["us-west-1a"] ''
+aws_amis (any): { eu-west-1 = "ami-b1cf19c6", us-east-1 = "ami-de7ab6b6", us-west-1 = "ami-3f75767a", us-west-2 = "ami-21f78e11" } {"eu-west-1":"ami-b1cf19c6","us-east-1":"ami-de7ab6b6","us-west-1":"ami-3f75767a","us-west-2":"ami-21f78e11"} ''
+docker_ports (list(object({external=number,internal=number,protocol=string}))): [{ external = 8300, internal = 8301, protocol = "tcp" }] [{"external":8300,"internal":8301,"protocol":"tcp"}] ''
+instance_name (any): "my-instance" "my-instance" ''
+password (string): (sensitive) null 'the root password to use with the database'
+region (any): null null ''
+with_optional_attribute (object({a=string,b=string,c=number})): { a = "val-a", b = null, c = 127 } {"a":"val-a","b":null,"c":127} ''
+`
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestTemplateShellQuote(t *testing.T) {
+	assert.Equal(t, `'it'\''s'`, templateShellQuote("it's"))
+}
@@ -0,0 +1,7 @@
+variable "before" {}
+
+resource "x" "y" {
+  foo = {
+  }
+
+variable "after" {}
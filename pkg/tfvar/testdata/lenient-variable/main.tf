@@ -0,0 +1,7 @@
+resource "aws_instance" "app" {
+  ami = var.image_id
+}
+
+variable "image_id" {
+  type = strin
+}
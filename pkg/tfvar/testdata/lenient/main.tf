@@ -0,0 +1,11 @@
+resource "aws_instance" "app" {
+  ami = var.image_id
+  tags = {
+    Name = "app"
+  }
+  instance_type =
+}
+
+variable "instance_type" {
+  default = "t3.micro"
+}
@@ -0,0 +1,7 @@
+variable "image_id" {
+  type = string
+}
+
+variable "region" {
+  default = "us-east-1"
+}
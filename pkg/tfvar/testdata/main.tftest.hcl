@@ -0,0 +1,12 @@
+# Shared by all runs
+variables {
+  region = "ap-northeast-1"
+}
+
+run "plan" {
+  command = plan
+
+  variables {
+    bucket = "plan"
+  }
+}
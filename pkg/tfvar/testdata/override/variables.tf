@@ -0,0 +1,6 @@
+variable "image_id" {
+  type    = string
+  default = "ami-abc123"
+}
+
+variable "region" {}
@@ -0,0 +1,3 @@
+variable "image_id" {
+  default = "ami-def456"
+}
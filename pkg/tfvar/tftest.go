@@ -0,0 +1,103 @@
+package tfvar
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// TFTestExt is the extension of Terraform test files.
+const TFTestExt = ".tftest.hcl"
+
+// tftestVariablesBlock is the block of Terraform test files that sets the variables of the module
+// under test, either at file level or inside run blocks.
+const tftestVariablesBlock = "variables"
+
+// IsTFTestFile reports whether filename is a Terraform test file, e.g. main.tftest.hcl.
+func IsTFTestFile(filename string) bool {
+	return strings.HasSuffix(filename, TFTestExt)
+}
+
+// WriteAsTFTest outputs the given vars as the file level variables block of Terraform test files, e.g.
+//    variables {
+//      region = "ap-northeast-1"
+//    }
+func WriteAsTFTest(w io.Writer, vars []Variable) error {
+	f := hclwrite.NewEmptyFile()
+	body := f.Body().AppendNewBlock(tftestVariablesBlock, nil).Body()
+
+	for _, v := range vars {
+		body.SetAttributeValue(v.Name, v.Value)
+	}
+
+	_, err := f.WriteTo(w)
+	return errors.Wrap(err, "tfvar: failed to write as tftest variables")
+}
+
+// SyncTFTest is like Sync but updates the file level variables block of an existing Terraform test file
+// (.tftest.hcl). Only the required vars, i.e. the ones without default value, that are missing from the
+// block are added. The block is created at the top of the file if it does not exist. Only the entries of
+// the file level block are added, and the rest of the file, including the run blocks, is left untouched
+// byte for byte. The entries that match none of vars are only reported, even if prune is true, since they
+// may still be used by run blocks that test another module.
+func SyncTFTest(src []byte, filename string, vars []Variable, prune bool) ([]byte, SyncResult, error) {
+	f, diags := hclwrite.ParseConfig(src, filename, hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		return nil, SyncResult{}, errors.Wrapf(diags, "tfvar: failed to parse '%s'", filename)
+	}
+
+	var block *hclwrite.Block
+
+	for _, b := range f.Body().Blocks() {
+		if b.Type() == tftestVariablesBlock && len(b.Labels()) == 0 {
+			block = b
+			break
+		}
+	}
+
+	// hclwrite can only append blocks, so a new file is made of the block followed by the existing content.
+	if block == nil {
+		head := hclwrite.NewEmptyFile()
+		block = head.Body().AppendNewBlock(tftestVariablesBlock, nil)
+
+		if len(bytes.TrimSpace(src)) > 0 {
+			head.Body().AppendNewline()
+			head.Body().AppendUnstructuredTokens(f.BuildTokens(nil))
+		}
+
+		f = head
+	}
+
+	result := syncBody(block.Body(), vars, false, func(v Variable) bool { return v.Required })
+
+	return formatBlock(f, block), result, nil
+}
+
+// formatBlock returns the content of f with only block formatted, so that the formatting of the
+// rest of the file, e.g. the alignment in run blocks, is kept as is.
+func formatBlock(f *hclwrite.File, block *hclwrite.Block) []byte {
+	tokens := f.BuildTokens(nil)
+	blockTokens := block.BuildTokens(nil)
+
+	// The tokens of block are the same pointers as the ones in the file.
+	for start := range tokens {
+		if len(blockTokens) == 0 || tokens[start] != blockTokens[0] {
+			continue
+		}
+
+		end := start + len(blockTokens)
+
+		var buf bytes.Buffer
+		buf.Write(tokens[:start].Bytes())
+		buf.Write(hclwrite.Format(blockTokens.Bytes()))
+		buf.Write(tokens[end:].Bytes())
+
+		return buf.Bytes()
+	}
+
+	return f.Bytes()
+}
@@ -0,0 +1,213 @@
+package tfvar
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestWriteAsTFTest(t *testing.T) {
+	vars := []Variable{
+		{Name: "region", Value: cty.StringVal("ap-northeast-1")},
+		{Name: "bucket", Value: cty.NullVal(cty.String), Required: true},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteAsTFTest(&buf, vars))
+	assert.Equal(t, `variables {
+  region = "ap-northeast-1"
+  bucket = null
+}
+`, buf.String())
+}
+
+func TestSyncTFTest(t *testing.T) {
+	src, err := ioutil.ReadFile("testdata/main.tftest.hcl")
+	require.NoError(t, err)
+
+	vars := []Variable{
+		{Name: "bucket", Value: cty.NullVal(cty.String), Required: true},
+		{Name: "instance_type", Value: cty.StringVal("t3.micro")},
+		{Name: "token", Value: cty.NullVal(cty.String), Required: true, Ephemeral: true},
+	}
+
+	type args struct {
+		src   []byte
+		prune bool
+	}
+
+	tests := []struct {
+		name       string
+		args       args
+		want       string
+		wantResult SyncResult
+		assertion  assert.ErrorAssertionFunc
+	}{
+		{
+			name: "keep unknown",
+			args: args{
+				src: src,
+			},
+			want: `# Shared by all runs
+variables {
+  region = "ap-northeast-1"
+  bucket = null
+  token  = null
+}
+
+run "plan" {
+  command = plan
+
+  variables {
+    bucket = "plan"
+  }
+}
+`,
+			wantResult: SyncResult{
+				Added:   []string{"bucket", "token"},
+				Unknown: []string{"region"},
+			},
+			assertion: assert.NoError,
+		},
+		{
+			name: "prune only reports unknown",
+			args: args{
+				src:   src,
+				prune: true,
+			},
+			want: `# Shared by all runs
+variables {
+  region = "ap-northeast-1"
+  bucket = null
+  token  = null
+}
+
+run "plan" {
+  command = plan
+
+  variables {
+    bucket = "plan"
+  }
+}
+`,
+			wantResult: SyncResult{
+				Added:   []string{"bucket", "token"},
+				Unknown: []string{"region"},
+			},
+			assertion: assert.NoError,
+		},
+		{
+			name: "no variables block",
+			args: args{
+				src: []byte("run \"apply\" {}\n"),
+			},
+			want: `variables {
+  bucket = null
+  token  = null
+}
+
+run "apply" {}
+`,
+			wantResult: SyncResult{
+				Added: []string{"bucket", "token"},
+			},
+			assertion: assert.NoError,
+		},
+		{
+			name: "empty file",
+			args: args{
+				src: nil,
+			},
+			want: `variables {
+  bucket = null
+  token  = null
+}
+`,
+			wantResult: SyncResult{
+				Added: []string{"bucket", "token"},
+			},
+			assertion: assert.NoError,
+		},
+		{
+			name: "hand formatted run block",
+			args: args{
+				src: []byte(`run "plan" {
+  command   = plan
+  variables {
+    bucket        = "plan"
+    unknown = 1 # not pruned
+  }
+}
+`),
+				prune: true,
+			},
+			want: `variables {
+  bucket = null
+  token  = null
+}
+
+run "plan" {
+  command   = plan
+  variables {
+    bucket        = "plan"
+    unknown = 1 # not pruned
+  }
+}
+`,
+			wantResult: SyncResult{
+				Added: []string{"bucket", "token"},
+			},
+			assertion: assert.NoError,
+		},
+		{
+			name: "hand formatted run block after variables",
+			args: args{
+				src: []byte(`variables {
+  region="ap-northeast-1"
+}
+
+run "plan" {
+  command   = plan
+}
+`),
+				prune: true,
+			},
+			want: `variables {
+  region = "ap-northeast-1"
+  bucket = null
+  token  = null
+}
+
+run "plan" {
+  command   = plan
+}
+`,
+			wantResult: SyncResult{
+				Added:   []string{"bucket", "token"},
+				Unknown: []string{"region"},
+			},
+			assertion: assert.NoError,
+		},
+		{
+			name: "bad file",
+			args: args{
+				src: []byte(`run {`),
+			},
+			want:      "",
+			assertion: assert.Error,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, result, err := SyncTFTest(tt.args.src, "main.tftest.hcl", vars, tt.args.prune)
+			tt.assertion(t, err)
+			assert.Equal(t, tt.want, string(got))
+			assert.Equal(t, tt.wantResult, result)
+		})
+	}
+}
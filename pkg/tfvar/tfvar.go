@@ -8,9 +8,11 @@ import (
 	"io"
 
 	"github.com/cockroachdb/errors"
+	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/hclsyntax"
 	"github.com/hashicorp/hcl/v2/hclwrite"
 	"github.com/shihanng/tfvar/pkg/configs"
+	"github.com/spf13/afero"
 	"github.com/zclconf/go-cty/cty"
 )
 
@@ -21,19 +23,67 @@ import (
 type Variable struct {
 	Name        string
 	Value       cty.Value
+	Type        cty.Type
 	Description string
 	Sensitive   bool
+	// Ephemeral is true for variables declared with ephemeral = true, whose values Terraform does
+	// not persist. Writers of formats meant to be stored, see IsPersistentFormat, skip them.
+	Ephemeral bool
+	// Deprecated is the message of an OpenTofu variable declared with deprecated = "...",
+	// or empty if the variable is not deprecated.
+	Deprecated string
+	// Required is true for variables declared without a default value.
+	Required bool
+	// History records where the attributes of the declaration were set, including the ones
+	// changed by override files (_override.tf), see WriteAsProvenance.
+	History []configs.VariableAttribute
 
 	parsingMode configs.VariableParsingMode
 }
 
 // Load extracts all input variables declared in the Terraform configurations located in dir.
 func Load(dir string) ([]Variable, error) {
-	parser := configs.NewParser(nil)
+	return LoadFS(nil, dir)
+}
+
+// LoadFS is like Load but reads the Terraform configurations from the given filesystem.
+// If a nil filesystem is passed then the system's "real" filesystem will be used.
+func LoadFS(fs afero.Fs, dir string) ([]Variable, error) {
+	vars, _, err := loadFS(fs, dir, Options{})
+	return vars, err
+}
+
+// LoadFSLenient is like LoadFS but only fails when the variable declarations themselves are broken.
+// Errors in the rest of the configurations, e.g. a syntax error in a resource block, are returned as
+// warnings along with the variables that could be decoded.
+func LoadFSLenient(fs afero.Fs, dir string) ([]Variable, []Warning, error) {
+	return loadFS(fs, dir, Options{Lenient: true})
+}
+
+// loadFS loads the variables of the module in dir following opts.Lenient and opts.Tofu.
+func loadFS(fs afero.Fs, dir string, opts Options) ([]Variable, []Warning, error) {
+	parser := configs.NewParser(fs)
+
+	if opts.Tofu != nil {
+		if *opts.Tofu {
+			parser.SetTofuMode(configs.TofuOn)
+		} else {
+			parser.SetTofuMode(configs.TofuOff)
+		}
+	}
 
 	modules, diag := parser.LoadConfigDir(dir)
-	if diag.HasErrors() {
-		return nil, errors.Wrap(diag, "tfvar: loading config")
+	if diag.HasErrors() && (!opts.Lenient || modules == nil || hasVariableErrors(diag)) {
+		return nil, nil, newDiagnosticsError("tfvar: loading config", diag, parser.Files())
+	}
+
+	var warnings []Warning
+
+	for _, d := range diag {
+		warnings = append(warnings, Warning{
+			Message:    d.Error(),
+			Diagnostic: d,
+		})
 	}
 
 	variables := make([]Variable, 0, len(modules.Variables))
@@ -42,14 +92,30 @@ func Load(dir string) ([]Variable, error) {
 		variables = append(variables, Variable{
 			Name:        v.Name,
 			Value:       v.Default,
+			Type:        v.Type,
 			Description: v.Description,
 			Sensitive:   v.Sensitive,
+			Ephemeral:   v.Ephemeral,
+			Deprecated:  v.Deprecated,
+			Required:    v.Default == cty.NilVal,
+			History:     v.History,
 
 			parsingMode: v.ParsingMode,
 		})
 	}
 
-	return variables, nil
+	return variables, warnings, nil
+}
+
+// hasVariableErrors reports whether any of the errors in diags is about variable declarations.
+func hasVariableErrors(diags hcl.Diagnostics) bool {
+	for _, d := range diags {
+		if d.Severity == hcl.DiagError && configs.IsVariableDiagnostic(d) {
+			return true
+		}
+	}
+
+	return false
 }
 
 const varEnvPrefix = "TF_VAR_"
@@ -58,11 +124,7 @@ const varEnvPrefix = "TF_VAR_"
 //    export TF_VAR_region='ap-northeast-1'
 func WriteAsEnvVars(w io.Writer, vars []Variable) error {
 	for _, v := range vars {
-		val := convertNull(v.Value)
-
-		t := hclwrite.TokensForValue(val)
-		t = oneliner(t)
-		b := hclwrite.Format(t.Bytes())
+		b := formatOneliner(convertNull(v.Value))
 		b = bytes.TrimPrefix(b, []byte(`"`))
 		b = bytes.TrimSuffix(b, []byte(`"`))
 
@@ -74,6 +136,14 @@ func WriteAsEnvVars(w io.Writer, vars []Variable) error {
 	return nil
 }
 
+// formatOneliner returns val in HCL syntax without any newline, e.g.
+//    { external = 8300, internal = 8300, protocol = "tcp" }
+func formatOneliner(val cty.Value) []byte {
+	t := hclwrite.TokensForValue(val)
+	t = oneliner(t)
+	return hclwrite.Format(t.Bytes())
+}
+
 func oneliner(original hclwrite.Tokens) hclwrite.Tokens {
 	var toks hclwrite.Tokens
 
@@ -101,11 +171,12 @@ func oneliner(original hclwrite.Tokens) hclwrite.Tokens {
 
 // WriteAsTFVars outputs the given vars in Terraform's variable definitions format, e.g.
 //    region = "ap-northeast-1"
+// Ephemeral variables are skipped.
 func WriteAsTFVars(w io.Writer, vars []Variable) error {
 	f := hclwrite.NewEmptyFile()
 	rootBody := f.Body()
 
-	for _, v := range vars {
+	for _, v := range persistentVariables(vars) {
 		rootBody.SetAttributeValue(v.Name, v.Value)
 	}
 
@@ -131,13 +202,11 @@ type workspaceAttributes struct {
 	Sensitive   bool   `json:"sensitive"`
 }
 
+// WriteAsWorkspacePayload outputs the given vars as payloads of the Workspace Variables API of
+// Terraform Cloud, one JSON object per variable. Ephemeral variables are skipped.
 func WriteAsWorkspacePayload(w io.Writer, vars []Variable) error {
-	for _, v := range vars {
-		val := convertNull(v.Value)
-
-		t := hclwrite.TokensForValue(val)
-		t = oneliner(t)
-		b := hclwrite.Format(t.Bytes())
+	for _, v := range persistentVariables(vars) {
+		b := formatOneliner(convertNull(v.Value))
 		b = bytes.TrimPrefix(b, []byte(`"`))
 		b = bytes.TrimSuffix(b, []byte(`"`))
 		b = bytes.ReplaceAll(b, []byte(`"`), []byte(`'`))
@@ -167,11 +236,13 @@ func WriteAsWorkspacePayload(w io.Writer, vars []Variable) error {
 	return nil
 }
 
+// WriteAsTFEResource outputs the given vars as tfe_variable resources of the Terraform Enterprise
+// (tfe) provider. Ephemeral variables are skipped.
 func WriteAsTFEResource(w io.Writer, vars []Variable) error {
 	f := hclwrite.NewEmptyFile()
 	rootBody := f.Body()
 
-	for _, v := range vars {
+	for _, v := range persistentVariables(vars) {
 		rootBody.AppendNewline()
 		resourceBlock := rootBody.AppendNewBlock("resource", []string{"tfe_variable", v.Name})
 		resourceBody := resourceBlock.Body()
@@ -187,6 +258,19 @@ func WriteAsTFEResource(w io.Writer, vars []Variable) error {
 	return errors.Wrap(err, "tfe_variable: failed to write as tfe_variable resource")
 }
 
+// persistentVariables returns vars without the ephemeral ones, whose values must not be stored.
+func persistentVariables(vars []Variable) []Variable {
+	persistent := make([]Variable, 0, len(vars))
+
+	for _, v := range vars {
+		if !v.Ephemeral {
+			persistent = append(persistent, v)
+		}
+	}
+
+	return persistent
+}
+
 func convertNull(v cty.Value) cty.Value {
 	if v.IsNull() {
 		return cty.StringVal("")
@@ -194,3 +278,11 @@ func convertNull(v cty.Value) cty.Value {
 
 	return v
 }
+
+func newAfero(fs afero.Fs) afero.Afero {
+	if fs == nil {
+		fs = afero.NewOsFs()
+	}
+
+	return afero.Afero{Fs: fs}
+}
@@ -5,8 +5,10 @@ import (
 	"sort"
 	"testing"
 
+	"github.com/hashicorp/hcl/v2"
 	"github.com/sebdah/goldie/v2"
 	"github.com/shihanng/tfvar/pkg/configs"
+	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/zclconf/go-cty/cty"
@@ -29,9 +31,15 @@ func TestLoad(t *testing.T) {
 				dir: "./testdata/normal",
 			},
 			want: []Variable{
-				{Name: "resource_name", parsingMode: configs.VariableParseLiteral},
-				{Name: "instance_name", Value: cty.StringVal("my-instance"), parsingMode: configs.VariableParseLiteral},
-				{Name: "object", parsingMode: configs.VariableParseHCL},
+				{Name: "resource_name", Type: cty.DynamicPseudoType, Required: true, parsingMode: configs.VariableParseLiteral},
+				{
+					Name: "instance_name", Value: cty.StringVal("my-instance"), Type: cty.DynamicPseudoType, parsingMode: configs.VariableParseLiteral,
+					History: history("default", "testdata/normal/main.tf", hcl.Pos{Line: 3, Column: 3, Byte: 57}, hcl.Pos{Line: 3, Column: 26, Byte: 80}),
+				},
+				{
+					Name: "object", Type: cty.Object(map[string]cty.Type{"name": cty.String}), Required: true, parsingMode: configs.VariableParseHCL,
+					History: history("type", "testdata/normal/main.tf", hcl.Pos{Line: 6, Column: 3, Byte: 105}, hcl.Pos{Line: 8, Column: 5, Byte: 153}),
+				},
 			},
 			assertion: assert.NoError,
 		},
@@ -54,6 +62,13 @@ func TestLoad(t *testing.T) {
 	}
 }
 
+// history returns the History of a variable declared with the single attribute name.
+func history(name, filename string, start, end hcl.Pos) []configs.VariableAttribute {
+	return []configs.VariableAttribute{
+		{Name: name, Range: hcl.Range{Filename: filename, Start: start, End: end}},
+	}
+}
+
 func TestWriteAsEnvVars(t *testing.T) {
 	vars, err := Load("testdata/defaults")
 	require.NoError(t, err)
@@ -142,3 +157,87 @@ func TestWriteAsWorkspacePayload(t *testing.T) {
 
 	g.Assert(t, "workspace_payload", buf.Bytes())
 }
+
+func TestLoadFS(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/module/main.tf", []byte(`variable "region" {
+  default = "ap-northeast-1"
+}
+`), 0644))
+
+	got, err := LoadFS(fs, "/module")
+	require.NoError(t, err)
+	assert.Equal(t, []Variable{
+		{
+			Name: "region", Value: cty.StringVal("ap-northeast-1"), Type: cty.DynamicPseudoType, parsingMode: configs.VariableParseLiteral,
+			History: history("default", "/module/main.tf", hcl.Pos{Line: 2, Column: 3, Byte: 22}, hcl.Pos{Line: 2, Column: 29, Byte: 48}),
+		},
+	}, got)
+
+	_, err = LoadFS(fs, "/unknown")
+	assert.Error(t, err)
+}
+
+func TestLoadFSLenient(t *testing.T) {
+	tests := []struct {
+		name         string
+		dir          string
+		wantNames    []string
+		wantWarnings []string
+		assertion    assert.ErrorAssertionFunc
+	}{
+		{
+			name:         "error in resource",
+			dir:          "testdata/lenient",
+			wantNames:    []string{"image_id", "instance_type", "region"},
+			wantWarnings: []string{"testdata/lenient/main.tf:6,18-7,1: Invalid expression; Expected the start of an expression, but found an invalid expression token."},
+			assertion:    assert.NoError,
+		},
+		{
+			name:      "error in variable",
+			dir:       "testdata/lenient-variable",
+			assertion: assert.Error,
+		},
+		{
+			name:      "variable in unclosed block",
+			dir:       "testdata/lenient-unclosed",
+			assertion: assert.Error,
+		},
+		{
+			name:      "broken variable header",
+			dir:       "testdata/bad",
+			assertion: assert.Error,
+		},
+		{
+			name:      "no directory",
+			dir:       "testdata/not-found",
+			assertion: assert.Error,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			vars, warnings, err := LoadFSLenient(nil, tt.dir)
+			tt.assertion(t, err)
+
+			var names []string
+			for _, v := range vars {
+				names = append(names, v.Name)
+			}
+
+			sort.Strings(names)
+			assert.Equal(t, tt.wantNames, names)
+
+			var messages []string
+			for _, w := range warnings {
+				messages = append(messages, w.String())
+			}
+
+			assert.Equal(t, tt.wantWarnings, messages)
+
+			_, err = LoadFS(nil, tt.dir)
+			assert.Error(t, err)
+		})
+	}
+}
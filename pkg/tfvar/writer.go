@@ -0,0 +1,114 @@
+package tfvar
+
+import (
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/cockroachdb/errors"
+)
+
+// Names of the formats registered by this package.
+const (
+	FormatTFVars           = "tfvars"
+	FormatEnvVars          = "env"
+	FormatTFEResource      = "resource"
+	FormatWorkspacePayload = "workspace"
+	FormatTFTest           = "tftest"
+)
+
+// persistentFormats are the formats registered by this package whose output is meant to be stored,
+// e.g. in a file or in Terraform Cloud.
+var persistentFormats = map[string]bool{
+	FormatTFVars:           true,
+	FormatTFEResource:      true,
+	FormatWorkspacePayload: true,
+}
+
+// IsPersistentFormat reports whether the output of the format name is meant to be stored, in which
+// case its writer skips ephemeral variables. The values of ephemeral variables can still be written
+// in the env format, since environment variables are not persisted.
+func IsPersistentFormat(name string) bool {
+	return persistentFormats[name]
+}
+
+// Writer outputs variables in a specific format.
+type Writer interface {
+	Write(w io.Writer, vars []Variable) error
+}
+
+// WriterFunc is an adapter to allow the use of ordinary functions, e.g. WriteAsTFVars, as Writer.
+type WriterFunc func(w io.Writer, vars []Variable) error
+
+// Write calls f(w, vars).
+func (f WriterFunc) Write(w io.Writer, vars []Variable) error {
+	return f(w, vars)
+}
+
+var (
+	writersMu sync.RWMutex
+	writers   = make(map[string]Writer)
+)
+
+func init() {
+	RegisterWriter(FormatTFVars, WriterFunc(WriteAsTFVars))
+	RegisterWriter(FormatEnvVars, WriterFunc(WriteAsEnvVars))
+	RegisterWriter(FormatTFEResource, WriterFunc(WriteAsTFEResource))
+	RegisterWriter(FormatWorkspacePayload, WriterFunc(WriteAsWorkspacePayload))
+	RegisterWriter(FormatTFTest, WriterFunc(WriteAsTFTest))
+	RegisterWriter(FormatProvenance, WriterFunc(WriteAsProvenance))
+}
+
+// RegisterWriter makes w available under the format name, e.g. for the --format flag of the CLI.
+// If RegisterWriter is called twice with the same name or if w is nil, it panics.
+func RegisterWriter(name string, w Writer) {
+	writersMu.Lock()
+	defer writersMu.Unlock()
+
+	if w == nil {
+		panic("tfvar: RegisterWriter writer is nil")
+	}
+
+	if _, dup := writers[name]; dup {
+		panic("tfvar: RegisterWriter called twice for format " + name)
+	}
+
+	writers[name] = w
+}
+
+// LookupWriter returns the Writer registered under the format name.
+func LookupWriter(name string) (Writer, error) {
+	writersMu.RLock()
+	w, found := writers[name]
+	writersMu.RUnlock()
+
+	if !found {
+		return nil, errors.Errorf("tfvar: unknown format '%s', must be one of: %s", name, strings.Join(Formats(), ", "))
+	}
+
+	return w, nil
+}
+
+// Formats returns the sorted names of the registered formats.
+func Formats() []string {
+	writersMu.RLock()
+	defer writersMu.RUnlock()
+
+	names := make([]string, 0, len(writers))
+	for name := range writers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// NewTemplateWriter returns a Writer that outputs the variables with tmpl, see WriteWithTemplate.
+func NewTemplateWriter(tmpl *template.Template) Writer {
+	return WriterFunc(func(w io.Writer, vars []Variable) error {
+		return WriteWithTemplate(w, tmpl, vars)
+	})
+}
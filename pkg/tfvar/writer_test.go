@@ -0,0 +1,106 @@
+package tfvar
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestFormats(t *testing.T) {
+	assert.Equal(t, []string{"env", "provenance", "resource", "tftest", "tfvars", "workspace"}, Formats())
+}
+
+func TestLookupWriter(t *testing.T) {
+	vars := []Variable{{Name: "region", Value: cty.StringVal("ap-northeast-1")}}
+
+	w, err := LookupWriter(FormatEnvVars)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, w.Write(&buf, vars))
+	assert.Equal(t, "export TF_VAR_region='ap-northeast-1'\n", buf.String())
+
+	_, err = LookupWriter("unknown")
+	assert.EqualError(t, err, "tfvar: unknown format 'unknown', must be one of: env, provenance, resource, tftest, tfvars, workspace")
+}
+
+func TestRegisterWriter(t *testing.T) {
+	names := WriterFunc(func(w io.Writer, vars []Variable) error {
+		for _, v := range vars {
+			if _, err := io.WriteString(w, v.Name+"\n"); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	RegisterWriter("test-names", names)
+	defer func() {
+		writersMu.Lock()
+		delete(writers, "test-names")
+		writersMu.Unlock()
+	}()
+
+	assert.Contains(t, Formats(), "test-names")
+	assert.Panics(t, func() { RegisterWriter("test-names", names) })
+	assert.Panics(t, func() { RegisterWriter("test-nil", nil) })
+
+	w, err := LookupWriter("test-names")
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, w.Write(&buf, []Variable{{Name: "a"}, {Name: "b"}}))
+	assert.Equal(t, "a\nb\n", buf.String())
+}
+
+func TestEphemeral(t *testing.T) {
+	vars := []Variable{
+		{Name: "region", Value: cty.StringVal("ap-northeast-1")},
+		{Name: "token", Value: cty.StringVal("secret"), Ephemeral: true},
+	}
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{
+			format: FormatTFVars,
+			want:   "region = \"ap-northeast-1\"\n",
+		},
+		{
+			format: FormatEnvVars,
+			want:   "export TF_VAR_region='ap-northeast-1'\nexport TF_VAR_token='secret'\n",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.format, func(t *testing.T) {
+			w, err := LookupWriter(tt.format)
+			require.NoError(t, err)
+
+			var buf bytes.Buffer
+			require.NoError(t, w.Write(&buf, vars))
+			assert.Equal(t, tt.want, buf.String())
+		})
+	}
+
+	for _, format := range []string{FormatTFEResource, FormatWorkspacePayload} {
+		w, err := LookupWriter(format)
+		require.NoError(t, err)
+
+		var buf bytes.Buffer
+		require.NoError(t, w.Write(&buf, vars))
+		assert.Contains(t, buf.String(), "region", format)
+		assert.NotContains(t, buf.String(), "token", format)
+	}
+
+	assert.True(t, IsPersistentFormat(FormatTFVars))
+	assert.True(t, IsPersistentFormat(FormatTFEResource))
+	assert.True(t, IsPersistentFormat(FormatWorkspacePayload))
+	assert.False(t, IsPersistentFormat(FormatEnvVars))
+}